@@ -34,6 +34,9 @@ cmd:
 
   profile <ip> operator (add|delete) <ip2>
   profile <ip> code
+  profile <ip> har <file.har> [<pack-name>]
+        import HAR as stores and url commands of profile <ip>,
+        optionally save the commands as a pack
 `)
 }
 
@@ -89,8 +92,13 @@ func version() {
 }
 
 var (
-	nodns   = flag.Bool("nodns", false, "nodns DISABLE the dns function")
-	dataDir = flag.String("datadir", "", "data dir save command packs, etc...")
+	nodns          = flag.Bool("nodns", false, "nodns DISABLE the dns function")
+	dataDir        = flag.String("datadir", "", "data dir save command packs, etc...")
+	cacheSize      = flag.Int64("cachesize", 1024, "max MB of disk cache in datadir, 0 for no limit")
+	histCount      = flag.Int("historycount", 0, "max count of histories in memory for each device, 0 for no limit")
+	histSize       = flag.Int64("historysize", 256, "max MB of histories in memory for each device, 0 for no limit")
+	histTotalCount = flag.Int("historytotalcount", 0, "max count of histories in memory for all devices, 0 for no limit")
+	histTotalSize  = flag.Int64("historytotalsize", 1024, "max MB of histories in memory for all devices, 0 for no limit")
 )
 
 func Main() {
@@ -104,6 +112,9 @@ func Main() {
 	}
 
 	p := proxy.NewProxy(VersionCode, *dataDir)
+	p.SetDiskCacheSize(*cacheSize * 1024 * 1024)
+	p.SetHistoryLimit(*histCount, *histSize*1024*1024)
+	p.SetTotalHistoryLimit(*histTotalCount, *histTotalSize*1024*1024)
 
 	ipProfiles := profile.NewIpProfiles(filepath.Join(*dataDir, "profiles"))
 	ipProfiles.BindProxyHostOperator(p.NewProxyHostOperator())
@@ -176,7 +187,7 @@ func Main() {
 				usage()
 			}
 		case "profile":
-			if !cmdProfile(rest, ipProfiles) {
+			if !cmdProfile(rest, ipProfiles, p) {
 				usage()
 			}
 
@@ -194,7 +205,7 @@ func Main() {
 	}
 }
 
-func cmdProfile(command string, ipProfiles *profile.IpProfiles) bool {
+func cmdProfile(command string, ipProfiles *profile.IpProfiles, p *proxy.Proxy) bool {
 	cmds := cmd.SplitCommand(command)
 	if len(cmds) < 2 {
 		return false
@@ -246,6 +257,41 @@ func cmdProfile(command string, ipProfiles *profile.IpProfiles) bool {
 		fmt.Printf("Access Code of profile %s is: %s\n", ip, prof.AccessCode())
 		return true
 
+	case "har":
+		if len(cmds) < 3 {
+			return false
+		}
+
+		packName := ""
+		if len(cmds) >= 4 {
+			packName = cmds[3]
+		}
+
+		f, err := os.Open(cmds[2])
+		if err != nil {
+			fmt.Printf("open HAR failed: %v\n", err)
+			return true
+		}
+
+		defer f.Close()
+		result, err := p.ImportHar(prof, f, packName, "")
+		if err != nil {
+			fmt.Printf("import HAR failed: %v\n", err)
+			return true
+		}
+
+		fmt.Print(result.Commands)
+		for _, e := range result.Errors {
+			fmt.Println(e)
+		}
+
+		fmt.Printf("%d urls imported to profile %s\n", len(result.Stores), ip)
+		if len(result.Pack) > 0 {
+			fmt.Printf("saved as pack %s\n", result.Pack)
+		}
+
+		return true
+
 	default:
 		fmt.Printf("unknown `profile' command `%s'", op)
 		return false
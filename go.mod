@@ -2,4 +2,7 @@ module github.com/benbearchen/asuran
 
 go 1.16
 
-require github.com/miekg/dns v1.1.38
+require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/miekg/dns v1.1.38
+)
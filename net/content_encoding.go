@@ -0,0 +1,73 @@
+package net
+
+import (
+	"github.com/andybalholm/brotli"
+
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func DecodeContent(encoding string, content []byte) ([]byte, error) {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return content, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+
+		defer r.Close()
+		reader = r
+	case "deflate":
+		// "deflate" should be zlib format, but some servers send raw deflate
+		r, err := zlib.NewReader(bytes.NewReader(content))
+		if err != nil {
+			r = flate.NewReader(bytes.NewReader(content))
+		}
+
+		defer r.Close()
+		reader = r
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(content))
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding: %s", encoding)
+	}
+
+	return ioutil.ReadAll(reader)
+}
+
+// EncodeContent compresses content by encoding, deflate in zlib format.
+func EncodeContent(encoding string, content []byte) ([]byte, error) {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return content, nil
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		w = zlib.NewWriter(&b)
+	case "br":
+		w = brotli.NewWriter(&b)
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding: %s", encoding)
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
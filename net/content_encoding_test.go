@@ -0,0 +1,71 @@
+package net
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"testing"
+)
+
+func TestDecodeContent(t *testing.T) {
+	content := []byte("hello, asuran")
+
+	var g bytes.Buffer
+	gw := gzip.NewWriter(&g)
+	gw.Write(content)
+	gw.Close()
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(content)
+	zw.Close()
+
+	var f bytes.Buffer
+	fw, _ := flate.NewWriter(&f, flate.DefaultCompression)
+	fw.Write(content)
+	fw.Close()
+
+	check := func(encoding string, b []byte) {
+		c, err := DecodeContent(encoding, b)
+		if err != nil {
+			t.Errorf("DecodeContent(%s) failed: %v", encoding, err)
+		} else if !bytes.Equal(c, content) {
+			t.Errorf("DecodeContent(%s) => %s", encoding, string(c))
+		}
+	}
+
+	check("", content)
+	check("identity", content)
+	check("gzip", g.Bytes())
+	check("deflate", z.Bytes())
+	check("deflate", f.Bytes())
+
+	if _, err := DecodeContent("compress", content); err == nil {
+		t.Errorf("DecodeContent(compress) should fail")
+	}
+}
+
+func TestEncodeContent(t *testing.T) {
+	content := bytes.Repeat([]byte("hello, asuran. "), 100)
+	for _, encoding := range []string{"identity", "gzip", "deflate", "br"} {
+		b, err := EncodeContent(encoding, content)
+		if err != nil {
+			t.Errorf("EncodeContent(%s) failed: %v", encoding, err)
+			continue
+		} else if encoding != "identity" && len(b) >= len(content) {
+			t.Errorf("EncodeContent(%s) not compressed: %d bytes", encoding, len(b))
+		}
+
+		c, err := DecodeContent(encoding, b)
+		if err != nil {
+			t.Errorf("DecodeContent(%s) failed: %v", encoding, err)
+		} else if !bytes.Equal(c, content) {
+			t.Errorf("DecodeContent(EncodeContent(%s)) changed: %s", encoding, string(c))
+		}
+	}
+
+	if _, err := EncodeContent("compress", content); err == nil {
+		t.Errorf("EncodeContent(compress) should fail")
+	}
+}
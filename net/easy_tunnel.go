@@ -11,6 +11,6 @@ func EasyTunnel(url string, w http.ResponseWriter, r *http.Request) error {
 	}
 
 	defer resp.Close()
-	resp.ProxyReturn(w, nil, false, false)
+	resp.ProxyReturn(w, nil, false, false, nil)
 	return nil
 }
@@ -36,7 +36,7 @@ func NewHttp(reqUrl string, r *http.Request, dial func(netw, addr string) (net.C
 
 	var postBody []byte
 	var body io.Reader = nil
-	if r != nil && r.Method == "POST" {
+	if r != nil && r.Body != nil && r.Method != "GET" && r.Method != "HEAD" {
 		b, err := ioutil.ReadAll(r.Body)
 		if err == nil {
 			postBody = b
@@ -103,36 +103,60 @@ func (r *HttpResponse) ResponseCode() int {
 	return r.resp.StatusCode
 }
 
-func (r *HttpResponse) ProxyReturn(w http.ResponseWriter, wrap io.Writer, recvFirst, forceChunked bool) ([]byte, error) {
+// edit, if not nil, receives the whole content first, and may modify the
+// header of the response, which would be copied to w after editing.
+func (r *HttpResponse) ProxyReturn(w http.ResponseWriter, wrap io.Writer, recvFirst, forceChunked bool, edit func(http.Header, []byte) ([]byte, error)) ([]byte, error) {
 	defer r.resp.Body.Close()
-	h := w.Header()
-	for k, v := range r.Header() {
-		h[k] = v
-	}
+	copyHeader := func() {
+		h := w.Header()
+		for k, v := range r.Header() {
+			h[k] = v
+		}
 
-	if forceChunked {
-		h.Del("Content-Length")
+		if forceChunked {
+			h.Del("Content-Length")
+		}
 	}
 
 	if wrap == nil {
 		wrap = w
 	}
 
-	if recvFirst {
+	if recvFirst || edit != nil {
 		bytes, err := ioutil.ReadAll(r.resp.Body)
 		if err == nil {
+			var editErr error
+			if edit != nil {
+				var edited []byte
+				edited, editErr = edit(r.Header(), bytes)
+				if editErr == nil {
+					bytes = edited
+
+					// the header is kept with the content, such as by cache
+					if len(r.Header().Get("Content-Length")) > 0 {
+						r.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+					}
+				}
+			}
+
+			copyHeader()
 			if !forceChunked {
 				w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
 			}
 
 			w.WriteHeader(r.ResponseCode())
 			_, err = wrap.Write(bytes)
+			if err == nil {
+				err = editErr
+			}
 		} else {
+			copyHeader()
 			w.WriteHeader(502)
 		}
 
 		return bytes, err
 	} else {
+		copyHeader()
 		w.WriteHeader(r.ResponseCode())
 
 		var b bytes.Buffer
@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+const bandwidthKeyword = "bandwidth"
+
+// BandwidthPolicy caps the total speed of a device, shared by all of its
+// connections. 0 means no limit.
+type BandwidthPolicy struct {
+	down float32 // B/s
+	up   float32 // B/s
+}
+
+type bandwidthPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(bandwidthPolicyFactory))
+}
+
+func (*bandwidthPolicyFactory) Keyword() string {
+	return bandwidthKeyword
+}
+
+func (*bandwidthPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) > 0 && args[0] == "off" {
+		return &BandwidthPolicy{}, args[1:], nil
+	}
+
+	p := &BandwidthPolicy{}
+	rest := args
+	for len(rest) >= 2 {
+		var speed *float32
+		switch rest[0] {
+		case "down":
+			speed = &p.down
+		case "up":
+			speed = &p.up
+		}
+
+		if speed == nil {
+			break
+		}
+
+		s, err := parseSpeed(rest[1])
+		if err != nil || s <= 0 {
+			return nil, args, fmt.Errorf(`%s %s invalid speed: %s`, bandwidthKeyword, rest[0], rest[1])
+		}
+
+		*speed = s
+		rest = rest[2:]
+	}
+
+	if p.Off() {
+		return nil, args, fmt.Errorf(`%s need (down <speed>|up <speed>|off)`, bandwidthKeyword)
+	}
+
+	return p, rest, nil
+}
+
+func (p *BandwidthPolicy) Keyword() string {
+	return bandwidthKeyword
+}
+
+func (p *BandwidthPolicy) Command() string {
+	if p.Off() {
+		return bandwidthKeyword + " off"
+	}
+
+	c := []string{bandwidthKeyword}
+	if p.down > 0 {
+		c = append(c, "down", formatSpeed(p.down))
+	}
+
+	if p.up > 0 {
+		c = append(c, "up", formatSpeed(p.up))
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *BandwidthPolicy) Comment() string {
+	if p.Off() {
+		return "不限制设备带宽"
+	}
+
+	c := make([]string, 0, 2)
+	if p.down > 0 {
+		c = append(c, "下行 "+formatSpeed(p.down))
+	}
+
+	if p.up > 0 {
+		c = append(c, "上行 "+formatSpeed(p.up))
+	}
+
+	return "设备总带宽 " + strings.Join(c, "，")
+}
+
+func (p *BandwidthPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *BandwidthPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *BandwidthPolicy) Off() bool {
+	return p.down <= 0 && p.up <= 0
+}
+
+func (p *BandwidthPolicy) Down() float32 {
+	return p.down
+}
+
+func (p *BandwidthPolicy) Up() float32 {
+	return p.up
+}
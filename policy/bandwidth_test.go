@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestBandwidthPolicy(t *testing.T) {
+	check := func(cmd string, down, up float32) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+		}
+
+		b, ok := p.(*BandwidthPolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+		} else if b.Down() != down || b.Up() != up {
+			t.Errorf(`Factory("%s") down/up: %v/%v vs %v/%v`, cmd, b.Down(), b.Up(), down, up)
+		}
+	}
+
+	check("bandwidth off", 0, 0)
+	check("bandwidth down 1MB/s", 1024*1024, 0)
+	check("bandwidth up 64KB/s", 0, 64*1024)
+	check("bandwidth down 512KB/s up 128KB/s", 512*1024, 128*1024)
+
+	bad := func(cmd string) {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+
+	bad("bandwidth")
+	bad("bandwidth down")
+	bad("bandwidth down x")
+	bad("bandwidth up 0")
+}
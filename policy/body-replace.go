@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"github.com/benbearchen/asuran/util/cmd"
+)
+
+const bodyReplaceKeyword = "body-replace"
+
+type BodyReplacePolicy struct {
+	stringPolicy
+	replacer *Replacer
+}
+
+func init() {
+	regFactory(newStringPolicyFactory(bodyReplaceKeyword, "/<regex>/<replacement>/[g]", func(val string) (Policy, error) {
+		replacer, err := NewFlagReplacer(val)
+		if err != nil {
+			return nil, err
+		}
+
+		return &BodyReplacePolicy{stringPolicy{bodyReplaceKeyword, val, func(val string) string {
+			return "替换返回内容 " + val
+		}}, replacer}, nil
+	}))
+}
+
+func (p *BodyReplacePolicy) Command() string {
+	return bodyReplaceKeyword + " " + cmd.Quote(p.str)
+}
+
+func (p *BodyReplacePolicy) Replace(content []byte) []byte {
+	return p.replacer.ReplaceBytes(content)
+}
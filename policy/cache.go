@@ -1,13 +1,224 @@
 package policy
 
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
 const cacheKeyword = "cache"
 
+const (
+	CacheKeyMethod   = "method"
+	CacheKeyQuery    = "query"
+	CacheKeyNoQuery  = "-query"
+	CacheKeyBodyHash = "body-hash"
+	CacheKeyHeader   = "header:"
+)
+
+// CachePolicy caches content in memory, or on disk with ttl or shared.
 type CachePolicy struct {
-	dogPolicy
+	ttl    float32 // 0 for no expiry
+	shared bool
+	key    []string
+}
+
+type cachePolicyFactory struct {
 }
 
 func init() {
-	regFactory(newDogPolicyFactory(cacheKeyword, func() Policy {
-		return &CachePolicy{dogPolicy{cacheKeyword, "缓存"}}
-	}))
+	regFactory(new(cachePolicyFactory))
+}
+
+func (*cachePolicyFactory) Keyword() string {
+	return cacheKeyword
+}
+
+func (*cachePolicyFactory) Build(args []string) (Policy, []string, error) {
+	p := &CachePolicy{}
+	for len(args) > 0 {
+		switch args[0] {
+		case "ttl":
+			if len(args) < 2 {
+				return nil, args, fmt.Errorf("%s ttl need a duration", cacheKeyword)
+			}
+
+			ttl, err := parseDuration(args[1])
+			if err != nil {
+				return nil, args, err
+			} else if ttl <= 0 {
+				return nil, args, fmt.Errorf("%s ttl should be greater than 0: %s", cacheKeyword, args[1])
+			}
+
+			p.ttl = ttl
+			args = args[2:]
+		case "shared":
+			p.shared = true
+			args = args[1:]
+		case "key":
+			if len(args) < 2 {
+				return nil, args, fmt.Errorf("%s key need parts", cacheKeyword)
+			}
+
+			key, err := parseCacheKey(args[1])
+			if err != nil {
+				return nil, args, err
+			}
+
+			p.key = key
+			args = args[2:]
+		default:
+			return p, args, nil
+		}
+	}
+
+	return p, args, nil
+}
+
+func parseCacheKey(s string) ([]string, error) {
+	key := make([]string, 0)
+	for _, k := range strings.Split(s, ",") {
+		switch {
+		case k == CacheKeyMethod, k == CacheKeyQuery, k == CacheKeyNoQuery, k == CacheKeyBodyHash:
+		case strings.HasPrefix(k, CacheKeyHeader) && len(k) > len(CacheKeyHeader):
+			k = CacheKeyHeader + textproto.CanonicalMIMEHeaderKey(k[len(CacheKeyHeader):])
+		default:
+			return nil, fmt.Errorf("%s key unknown part: %s, should be method|query|-query|header:<name>|body-hash", cacheKeyword, k)
+		}
+
+		key = append(key, k)
+	}
+
+	hasQuery, noQuery := false, false
+	for _, k := range key {
+		hasQuery = hasQuery || k == CacheKeyQuery
+		noQuery = noQuery || k == CacheKeyNoQuery
+	}
+
+	if hasQuery && noQuery {
+		return nil, fmt.Errorf("%s key can't have both %s and %s", cacheKeyword, CacheKeyQuery, CacheKeyNoQuery)
+	}
+
+	return key, nil
+}
+
+func (p *CachePolicy) Keyword() string {
+	return cacheKeyword
+}
+
+func (p *CachePolicy) Command() string {
+	c := cacheKeyword
+	if p.ttl > 0 {
+		c += " ttl " + formatDuration(p.ttl)
+	}
+
+	if p.shared {
+		c += " shared"
+	}
+
+	if len(p.key) > 0 {
+		c += " key " + strings.Join(p.key, ",")
+	}
+
+	return c
+}
+
+func (p *CachePolicy) Comment() string {
+	c := "缓存"
+	if p.Disk() {
+		c = "缓存到磁盘"
+	}
+
+	if p.shared {
+		c += "，所有设备共享"
+	}
+
+	if p.ttl > 0 {
+		c += "，" + formatDuration(p.ttl) + " 后过期"
+	}
+
+	if len(p.key) > 0 {
+		c += "，以 url 与 " + strings.Join(p.key, ",") + " 区分内容"
+	}
+
+	return c
+}
+
+func (p *CachePolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *CachePolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+// Disk tells whether the content should be saved on disk, which
+// survives restart of profile or asuran.
+func (p *CachePolicy) Disk() bool {
+	return p.ttl > 0 || p.shared
+}
+
+// TTL returns 0 for no expiry.
+func (p *CachePolicy) TTL() time.Duration {
+	return time.Duration(float64(p.ttl) * float64(time.Second))
+}
+
+func (p *CachePolicy) Shared() bool {
+	return p.shared
+}
+
+func (p *CachePolicy) has(part string) bool {
+	for _, k := range p.key {
+		if k == part {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AnyMethod tells whether requests other than GET could be cached, which
+// needs method in key.
+func (p *CachePolicy) AnyMethod() bool {
+	return p.has(CacheKeyMethod)
+}
+
+// NeedBody tells whether Key needs the request body.
+func (p *CachePolicy) NeedBody() bool {
+	return p.has(CacheKeyBodyHash)
+}
+
+// Key makes the cache key of a request. It's the whole url, without query
+// only if -query is in key, and followed by other parts of key.
+func (p *CachePolicy) Key(url, method string, header http.Header, body []byte) string {
+	if len(p.key) == 0 {
+		return url
+	}
+
+	key := url
+	if p.has(CacheKeyNoQuery) {
+		if q := strings.IndexByte(key, '?'); q >= 0 {
+			key = key[:q]
+		}
+	}
+
+	for _, k := range p.key {
+		switch {
+		case k == CacheKeyMethod:
+			key += " " + method
+		case k == CacheKeyBodyHash:
+			key += fmt.Sprintf(" body=%x", md5.Sum(body))
+		case strings.HasPrefix(k, CacheKeyHeader):
+			name := k[len(CacheKeyHeader):]
+			key += " " + name + "=" + strings.Join(header.Values(name), ",")
+		}
+	}
+
+	return key
 }
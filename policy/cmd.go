@@ -9,15 +9,30 @@ url [(set|update)] [settings...] [<url-pattern>|all]
 
 settings... ::=
       [drop <duration>]
-      [(delay|timeout) [body] [rand] <duration>]
-      [(proxy|cache|status <responseCode>|(map|redirect) (<resource-url>|replace /<match>/<new>/)|rewrite <url-encoded-content>|restore <store-id>|tcpwrite <url-encoded-content>)]
+      [(delay|timeout) [body] ([rand] <duration>|<distribution>)]
+      [(proxy|cache [ttl <duration>] [shared] [key <cache-key>]|status <responseCode>|(map|redirect) (<resource-url>|replace /<match>/<new>/)|rewrite [template] <url-encoded-content>|restore [template] <store-id>|tcpwrite [template] <url-encoded-content>|tcpscript [store] <script>|malformed <kind>)]
       [chunked (default|on|off|block <n>|size <n>[,<n2>[...]])]
       [speed <speeds>]
+      [upload-speed <speeds>]
+      [upload-delay <duration>]
+      [cut (<bytes>|<percent>%)]
+      [corrupt <rate>]
+      [stall (<bytes>|<percent>%) <duration>]
+      [hang [partial] [<duration>]]
       [(dont302|do302)]
       [(disable304|allow304)]
       [content-type (default|remove|empty|<content-type>)]
+      [encoding (identity|gzip|deflate|br)]
       [(request-headers|response-headers) <header-settings>]
+      [cookies <cookie-settings>]
+      [cors (allow [<origins>]|strip)]
+      [network <network-setting>]
       [host <ip:port>]
+      [body-replace /<regex>/<replacement>/[g]]
+      [json-set <json-path>=<json-value> [<json-path>=<json-value>...]]
+      [json-delete <json-path> [<json-path>...]]
+      [json-patch <url-encoded-json-patch>]
+      [request-body (rewrite <url-encoded-content>|replace /<regex>/<replacement>/[g]|json-patch <url-encoded-json-patch>)]
       [plugin [setting <setting-value>] <plugin-name>]
       [plugin set <setting-name>=<value> <plugin-name>]
       [plugin delete <setting-name> <plugin-name>]
@@ -26,10 +41,24 @@ url remove <setting-keyword> [<url-pattern>|all]
 
 url delete (<url-pattern>|all)
 
-domain ([default]|block|proxy|null) (delay [rand] <duration>) [shuffle] [n <n>] [circular] (<domain-name>|all) [<ip>[,<ip>...]]
+domain ([default]|block|proxy|null) (delay ([rand] <duration>|<distribution>)) [shuffle] [n <n>] [circular] (<domain-name>|all) [<ip>[,<ip>...]]
 
 domain delete (<domain-name>|all)
 
+network <network-setting>
+
+<network-setting> ::= (2g|3g|lte|lossy-wifi|off|<pack-name>|custom [rtt <duration>] [jitter <duration>] [down <speed>] [up <speed>] [reset <rate>])
+
+bandwidth ([down <speed>] [up <speed>]|off)
+
+offline (on [exact|ignore-query|fuzzy] [status <responseCode>]|off)
+
+record (on <session>|off)
+
+replay (on <session> [strict] [ordered] [body]|off)
+
+history ([count <n>] [size <size>] [evict]|off)
+
 
 compatible commands:
 -------
@@ -75,16 +104,52 @@ url command:
               允许对 headers 和 body 独立设置。
               特殊地，timeout body 在发送进行 duration 时长后断开链接。
     rand      不使用固定时长，而是随机生成 [0, 1) * duration。
+    <distribution>
+              不使用固定时长，而是按分布随机生成时长，不能与 rand 同用：
+      normal <mean> <stddev>
+              正态分布，均值 mean，标准差 stddev，小于 0 时取 0。
+      exponential <mean>
+              指数分布，均值 mean。
+      pareto <min> <alpha>
+              帕累托分布，最小 min，alpha 越小长尾越明显，如 1.5，最长 1h。
+      percentiles p<n>=<duration>[,p<n>=<duration>...]
+              按百分位分布，如 percentiles p50=100ms,p90=500ms,p99=2s，
+              百分位之间线性插值，小于最小百分位取最小值，
+              大于最大百分位取最大值。
 
 
               下面几种内容模式只能多选一：
 	      [默认] proxy
     proxy     代理 URL 请求结果。
     cache     缓存源 URL 请求结果，下次请求起从缓存返回。
+    cache [ttl <duration>] [shared]
+              带 ttl 或 shared 时缓存到 datadir 下的 cache 目录，
+              不受“重新开始”、闲置清理与 asuran 重启影响。
+              ttl 为过期时间，不设置则不过期；
+              shared 表示所有设备共享同一份缓存，否则各设备独立。
+              磁盘缓存总大小由启动参数 -cachesize（MB）限制，
+              超出时淘汰最久未用的内容。
+              如 cache ttl 24h shared
+    cache key <cache-key>
+              指定区分缓存内容的依据，默认为完整的 URL。
+              <cache-key> 以逗号分隔，可以是：
+              method        请求方法，带此项时 POST 等请求也会缓存
+              query         URL 参数，默认即区分，可省略
+              -query        忽略 URL 参数
+              header:<name> 请求头 name 的值
+              body-hash     请求内容的 md5
+              源站回复带 Vary 时，同时按 Vary 中的请求头区分内容；
+              Vary: * 的回复不缓存。
+              如 cache key method,header:Authorization,body-hash
+              Range 请求（含多段、后缀与 If-Range）可从整份缓存截取；
+              分段请求凑齐整份内容后也会合并为整份缓存。
     status <responseCode>
               对请求直接以 responseCode 回应。
               responseCode 可以是 404、502 等，
               但不应该是 200、302 等。
+              与 rewrite、restore 同用时，以 responseCode 返回其内容，
+              如 url restore s1 status 404 g.cn/，
+              内容不存在时仍以 responseCode 回应，内容为空。
               警告：status 以后可能作为独立设置
     map <resource-url>
               代理将请求 resource-url 的内容并返回。
@@ -95,13 +160,46 @@ url command:
               对目标 url 用 <new> 替换 <match> 后再去 map/redirect。
               <match> 必须是正则表达式（可包含捕获项），
               而 <new> 可由普通字符串或捕获结果混合组成。
-    rewrite <url-encoded-content>
+    rewrite [template] <url-encoded-content>
               以 url-encoded-content 的原始内容返回。
-    restore <store-id>
+    restore [template] <store-id>
               以预先保存的名字为 store-id 的内容返回。
               store-id 内容可以上传，也可以从请求历史修改。
-    tcpwrite <url-encoded-content>
+    tcpwrite [template] <url-encoded-content>
               直接以 TCP 而不是 HTTP 格式返回内容
+    template  以上三者加 template 时，内容作为 Go text/template 模板，
+              按请求渲染后再返回。模板可用字段：
+              .Method .URL .Scheme .Host .Path .RawQuery
+              .Query（参数表）.Header（请求头）.Body（POST 内容）
+              .JSON（POST 内容按 JSON 解析的结果）
+              .Captures（url 规则中各 * 匹配到的内容，按顺序）
+              .Now（当前时间）.Counter（该规则被渲染的次数，从 1 开始）
+              可用函数：query .Query "key"、capture .Captures 0、
+              unix .Now、unixms .Now
+              如 rewrite template %7B%22id%22%3A%22%7B%7Bcapture%20.Captures%200%7D%7D%22%7D
+    tcpscript [store] <script>
+              以 TCP 按脚本分段返回，用于测试客户端协议处理的健壮性。
+              脚本由逗号或换行分隔的步骤组成，以 # 开头的行为注释：
+              send:<url-encoded-content>  发送一段内容
+              sleep:<duration>            等待一段时间
+              halfclose                   关闭写方向（发送 FIN），仍可继续读
+              reset                       以 RST 断开连接，须为最后一步
+              close                       正常断开连接，须为最后一步
+              脚本结束后断开连接。
+              加 store 时 <script> 为 store-id，脚本取自预先保存的内容，
+              每次请求时读取。
+              如 tcpscript send:HTTP%2F1.1%20200%20OK%0D%0AContent-Length%3A%204%0D%0A%0D%0Aab,sleep:2s,send:cd,halfclose
+    malformed <kind>
+              以 TCP 返回内置的畸形 HTTP 回复，用于测试客户端的健壮性。
+              <kind> 可以是：
+              bad-status        错误的状态行
+              length-over       Content-Length 大于实际内容
+              length-under      Content-Length 小于实际内容
+              dup-headers       重复且冲突的 Content-Length、Content-Type
+              bad-chunk         非法的 chunk 长度
+              no-final-chunk    缺少结束的 0 长度 chunk
+              non-ascii-header  header 含非 ASCII 字节
+              http09            HTTP/0.9 式，没有状态行与 headers
 
 
     chunked default|on|off|block <n>|size <n>[,<n2>[...]]
@@ -121,6 +219,40 @@ url command:
               如 100, 99KB, 0.5MB/s 均可。
 
 
+    upload-speed <speeds>
+              限制读取客户端请求内容（如 POST 数据）的速度，
+              读完后才转发给服务器，单位同 speed。
+
+    upload-delay <duration>
+              延时 <duration> 后才开始读取客户端请求内容，
+              可与 upload-speed 同时使用，模拟慢速上传。
+              历史记录的详情会显示上传耗时。
+
+
+    cut (<bytes>|<percent>%)
+              回复内容传输 <bytes> 字节（或全部内容的 <percent>%）后
+              直接断开连接，模拟下载中断。<bytes> 支持 KB、MB，如 10KB。
+              使用百分比时，代理会先接收完整内容以计算长度。
+
+    corrupt <rate>
+              以 <rate> 的比率随机篡改回复内容的字节，如 0.1% 或 0.001；
+              历史记录保存的仍是原始内容。
+
+    stall (<bytes>|<percent>%) <duration>
+              回复内容传输至指定位置时暂停 <duration>，然后继续。
+
+              cut、corrupt、stall 可与 speed、chunked、network 等同时使用，
+              对 cache、rewrite、restore、tcpwrite、tcpscript 的内容同样有效。
+
+
+    hang [partial] [<duration>]
+              接受连接后不作任何回复，也不断开，用于触发客户端读超时。
+              partial 表示只发送状态行与部分 headers（不发送结束的空行）。
+              <duration> 后断开连接；不设置则一直挂起，
+              直到设备“重新开始”或者客户端自己断开。
+              挂起中的请求在“正在请求”中标记为 [hang]。
+
+
     dont302, do302
               决定是否由 asuran 来执行 302 跳转，二选一。[默认] dont302
               dont302 可以让客户端收到 302 跳转；
@@ -152,11 +284,90 @@ url command:
               ** <header-settings> 可能需要二阶 URL Encode
               ** 其它对 Headers 操作如 content-type/disable304 要早于本策略
 
+    cookies <cookie-settings>
+              对请求的 Cookie 或回复的 Set-Cookie 按单个 cookie 进行操作。
+              <cookie-settings> 每一行一个操作，以 request 或 response 开头：
+              request +<name>=<value>      增加请求 cookie
+              request -<name>              删除请求 cookie
+              request [=]<name>=<value>    修改请求 cookie，不存在则增加
+              response -<name>             删除回复中 <name> 的 Set-Cookie
+              response +<set-cookie>       增加一行 Set-Cookie，如 +vip=1; Path=/
+              response [=]<name> <attrs>   修改回复中 <name> 的 Set-Cookie 属性
+              <attrs> 为空格分隔的：expires=(<duration>|0|session)、
+              samesite=(lax|strict|none|default)、secure、nosecure、
+              httponly、nohttponly
+              expires=0 表示立即过期，session 表示会话 cookie。
+              ** <cookie-settings> 需要 URL Encode
+
+    cors allow [<origins>]
+    cors strip
+              allow 表示允许跨域访问：OPTIONS 预检请求由 asuran 直接回应，
+              其它回复加上 Access-Control-Allow-Origin 等 CORS 头。
+              <origins> 为逗号分隔的来源，如 http://localhost:3000,https://a.com，
+              省略或为 * 时允许任意来源。
+              strip 表示移除服务器回复的所有 Access-Control-* 头。
+
+    network <network-setting>
+              模拟网络状况，综合往返延时、抖动、上下行带宽与随机断开。
+              可作为 url 设置（包括缺省目标），也可作为独立命令设置整个设备；
+              url 的设置优先于设备的设置，url 可用 network off 取消设备的设置。
+              内置：
+              2g          延时 800ms±200ms，下行 32KB/s，上行 16KB/s
+              3g          延时 300ms±100ms，下行 200KB/s，上行 94KB/s
+              lte         延时 70ms±20ms，下行 1.5MB/s，上行 640KB/s
+              lossy-wifi  延时 40ms±150ms，下行 1MB/s，上行 512KB/s，断开率 5%
+              custom      自定义 rtt（往返延时）、jitter（抖动）、
+                          down、up（带宽，同 speed）与 reset（断开率，如 5%）
+              <pack-name> 使用名为 <pack-name> 的命令包里的 network custom 设置
+              延时作用于回复的第一个数据，与 delay body、speed 等可叠加。
+
 
     host <ip:port>
               指定实际连接的服务器地址
 
 
+    body-replace /<regex>/<replacement>/[g]
+              以正则表达式替换服务器返回的内容，其余内容保持不变。
+              <replacement> 可以用 ${1} 等引用捕获项；
+              不加 g 只替换第一处，加 g 替换所有匹配处。
+              gzip、deflate、br 压缩的内容会先解压再替换，
+              并以不压缩的形式返回，Content-Length 也会重新计算。
+              可与 speed、chunked 等同时使用。
+              <regex> 与 <replacement> 中的“/”写作“\/”，也可改用其它分隔符，
+              如 body-replace |https://a.com/|https://b.com/|g；
+              其余字符原样使用，不做 URL 解码，包含空格时整项用左引用
+              （即“` + "`" + `”）括起来，如 body-replace ` + "`" + `/a b/c d/g` + "`" + `。
+
+    json-set <json-path>=<json-value> [<json-path>=<json-value>...]
+    json-delete <json-path> [<json-path>...]
+    json-patch <url-encoded-json-patch>
+              修改服务器返回的 JSON 内容，其余内容保持不变。
+              <json-path> 以 $ 开头，如 $.data.user.vip、$.list[0]、$["a b"]；
+              <json-value> 为 JSON 值，如 true、1、"str"、{"a":1}，
+              包含空格时整项用左引用（即“` + "`" + `”）括起来。
+              json-set 的中间路径必须存在，最后一级不存在时会新建。
+              json-patch 为 RFC 6902 格式（add/remove/replace/move/copy/test），
+              其路径为 JSON Pointer，如 /data/user/vip；整个 patch 要么全部生效，要么不生效。
+              三者同时存在时，按 json-patch、json-set、json-delete 的顺序执行。
+              内容不是 JSON 或者路径不存在时，原样返回，错误记录到请求历史中。
+
+    request-body rewrite <url-encoded-content>
+    request-body replace /<regex>/<replacement>/[g]
+    request-body json-patch <url-encoded-json-patch>
+              在发往服务器前修改 POST/PUT/PATCH/DELETE 的请求内容。
+              rewrite 整体替换为 url-encoded-content 的原始内容；
+              replace 与 body-replace 相同（写法与转义规则一致），
+              json-patch 与上面的 json-patch 相同。
+              请求历史会同时记录原始内容与修改后的内容。
+
+    encoding (identity|gzip|deflate|br)
+              将服务器返回的内容解压后，按指定方式重新压缩返回，
+              Content-Encoding 与 Content-Length 随之修改；identity 为不压缩。
+              同时向服务器请求的 Accept-Encoding 改为指定的方式。
+              可与 body-replace、json-set 等同时使用，先修改再压缩；
+              对 rewrite、restore 的内容同样有效，断点续传（206）的内容不处理。
+
+
     plugin <plugin-name>
     plugin setting <setting-value> <plugin-name>
     plugin set <setting-name>=<value> <plugin-name>
@@ -210,7 +421,7 @@ domain mode:
     proxy     返回 asuran IP，以代理设备 HTTP 请求。
     null      返回查询无结果
 
-    delay [rand] <duration>
+    delay ([rand] <duration>|<distribution>)
               延时后返回，定义与 url delay 相同（不支持 body）
 
     shuffle
@@ -233,6 +444,48 @@ domain mode:
               不设置则在需要返回 IP 时由 asuran 查询实际 IP。
 
 
+device command:
+    bandwidth ([down <speed>] [up <speed>]|off)
+              限制设备的总带宽，由设备所有 HTTP 回复、上传、
+              websocket 与 HTTPS 隧道共享，并发多个下载时总和也不超过设置。
+              down、up 分别为下行、上行速度，格式同 url speed，
+              只设置其中一个则另一方向不限制；off 取消限制。
+              可与 url 的 speed、network 同时生效，以较慢者为准。
+    offline (on [exact|ignore-query|fuzzy] [status <responseCode>]|off)
+              离线模式，不再请求源站，从最近一次匹配的历史记录回复，
+              历史中没有时再查磁盘缓存；
+              磁盘缓存按 url 的 cache key 完全匹配，不受下面匹配方式影响。
+              exact        URL 完全一致（默认）
+              ignore-query 忽略 URL 参数
+              fuzzy        域名与路径一致，URL 参数越接近越优先
+              都找不到时以 responseCode 回应，默认 504，
+              并在历史中记为 offline miss，以便查看缺少哪些记录。
+              status、rewrite、cache 等 url 设置照常生效；off 恢复在线。
+    record (on <session>|off)
+              录制设备经源站代理的每次请求与回复，
+              追加保存到 -datadir 下 sessions/<session> 中，重启后仍在。
+              <session> 由字母、数字、-、_、. 组成。off 停止录制。
+    replay (on <session> [strict] [ordered] [body]|off)
+              以录制的会话回复设备请求，可用于任意设备。
+              按 method 与 URL 匹配，默认回复最早一次录制。
+              strict  会话中没有匹配时回应 502，并在历史中记为 replay miss，
+                      否则照常请求源站
+              ordered 相同请求依次回复各次录制，全部回复后视为没有匹配；
+                      重新设置 replay 则从头开始
+              body    还要求 POST 内容一致
+              replay 在 offline 之前生效；off 停止回放。
+    history ([count <n>] [size <size>] [evict]|off)
+              限制设备留在内存中的历史，count 为条数，size 为总字节数，
+              格式同 cut 的 <bytes>，如 64MB。超出时最旧的历史转存到
+              -datadir 下 history/<ip> 中，查看详情时再从磁盘读出；
+              evict 则直接丢弃。转存的历史不参与搜索与 HAR 导出，结果中
+              注明略过的条数；offline 只在 URL 完全一致时查找转存的历史。
+              off 使用全局限制，即 asuran 启动参数 -historycount 与
+              -historysize，默认每设备 256MB。设备列表中可看到用量。
+              所有设备合计另受 -historytotalcount 与 -historytotalsize
+              限制，默认共 1GB，超出时用量最大的设备先转存。
+
+
 -------
 examples:
 
@@ -251,5 +504,15 @@ domain block baidu.com
 domain proxy g.cn
 
 domain delete g.cn
+
+bandwidth down 1MB/s up 256KB/s
+
+offline on ignore-query status 404
+
+record on login-flow
+
+replay on login-flow strict ordered
+
+history count 1000 size 64MB
 `
 }
@@ -0,0 +1,379 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const cookiesKeyword = "cookies"
+
+const (
+	cookieRequest  = "request"
+	cookieResponse = "response"
+)
+
+type cookieSetting struct {
+	response bool
+	act      headerSettingAction
+	name     string
+	value    string
+
+	// for modify Set-Cookie
+	expires  *string
+	sameSite *http.SameSite
+	secure   *bool
+	httpOnly *bool
+
+	// for inject Set-Cookie
+	cookie string
+}
+
+type CookiesPolicy struct {
+	stringPolicy
+	settings []cookieSetting
+}
+
+func init() {
+	regFactory(newStringPolicyFactory(cookiesKeyword, "cookie-settings", func(val string) (Policy, error) {
+		settings, err := parseCookieSettings(val)
+		if err != nil {
+			return nil, err
+		}
+
+		return &CookiesPolicy{stringPolicy{cookiesKeyword, val, func(val string) string {
+			return "设定请求 Cookie 与回复 Set-Cookie"
+		}}, settings}, nil
+	}))
+}
+
+func (c *CookiesPolicy) Update(p Policy) error {
+	switch p := p.(type) {
+	case *CookiesPolicy:
+		c.str = p.str
+		c.settings = p.settings
+	default:
+		return fmt.Errorf("unmatch policy: %v", p)
+	}
+
+	return nil
+}
+
+func parseCookieSettings(setting string) ([]cookieSetting, error) {
+	uss, err := url.QueryUnescape(setting)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := strings.Split(uss, "\n")
+	settings := make([]cookieSetting, 0, len(ss))
+	for _, s := range ss {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+
+		side, s := takeWord(s)
+		var c cookieSetting
+		switch side {
+		case cookieRequest:
+		case cookieResponse:
+			c.response = true
+		default:
+			return nil, fmt.Errorf(`cookie setting should start with "%s" or "%s": %q`, cookieRequest, cookieResponse, s)
+		}
+
+		c.act = HSA_MODIFY
+		if len(s) > 0 {
+			switch s[0] {
+			case '+':
+				c.act = HSA_ADD
+				s = s[1:]
+			case '-':
+				c.act = HSA_DELETE
+				s = s[1:]
+			case '=':
+				c.act = HSA_MODIFY
+				s = s[1:]
+			}
+		}
+
+		s = strings.TrimSpace(s)
+		if c.response && c.act == HSA_ADD {
+			c.cookie = s
+			cookies := (&http.Response{Header: http.Header{"Set-Cookie": {s}}}).Cookies()
+			if len(cookies) == 0 {
+				return nil, fmt.Errorf("invalid Set-Cookie: %q", s)
+			}
+
+			c.name = cookies[0].Name
+		} else if c.response && c.act == HSA_MODIFY {
+			c.name, s = takeWord(s)
+			for len(s) > 0 {
+				var attr string
+				attr, s = takeWord(s)
+				err := c.parseAttr(attr)
+				if err != nil {
+					return nil, err
+				}
+			}
+		} else if c.act == HSA_DELETE {
+			c.name = s
+		} else {
+			kv := strings.SplitN(s, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("cookie NEED a value: %q", s)
+			}
+
+			c.name = strings.TrimSpace(kv[0])
+			c.value = strings.TrimSpace(kv[1])
+		}
+
+		if len(c.name) == 0 || strings.IndexAny(c.name, "=; \t") >= 0 {
+			return nil, fmt.Errorf("invalid cookie name: %q", c.name)
+		}
+
+		settings = append(settings, c)
+	}
+
+	return settings, nil
+}
+
+func takeWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	e := strings.IndexAny(s, " \t")
+	if e < 0 {
+		return s, ""
+	} else {
+		return s[:e], strings.TrimSpace(s[e:])
+	}
+}
+
+func (c *cookieSetting) parseAttr(attr string) error {
+	kv := strings.SplitN(attr, "=", 2)
+	key := strings.ToLower(kv[0])
+	value := ""
+	if len(kv) > 1 {
+		value = kv[1]
+	}
+
+	yes := true
+	no := false
+	switch key {
+	case "expires":
+		if value != "session" && value != "0" {
+			if _, err := parseDuration(value); err != nil {
+				return fmt.Errorf(`cookie expires should be <duration>, 0 or session: %q`, attr)
+			}
+		}
+
+		c.expires = &value
+	case "samesite":
+		var s http.SameSite
+		switch strings.ToLower(value) {
+		case "lax":
+			s = http.SameSiteLaxMode
+		case "strict":
+			s = http.SameSiteStrictMode
+		case "none":
+			s = http.SameSiteNoneMode
+		case "", "default":
+			s = http.SameSiteDefaultMode
+		default:
+			return fmt.Errorf(`cookie samesite should be lax, strict, none or default: %q`, attr)
+		}
+
+		c.sameSite = &s
+	case "secure":
+		c.secure = &yes
+	case "nosecure":
+		c.secure = &no
+	case "httponly":
+		c.httpOnly = &yes
+	case "nohttponly":
+		c.httpOnly = &no
+	default:
+		return fmt.Errorf("unknown cookie attribute: %q", attr)
+	}
+
+	return nil
+}
+
+// ApplyRequest modifies the Cookie header of request. Only the pairs named
+// by settings are touched, others keep their original text even if
+// net/http could not parse them.
+func (c *CookiesPolicy) ApplyRequest(header http.Header) {
+	pairs := make([]string, 0)
+	for _, line := range header["Cookie"] {
+		for _, pair := range strings.Split(line, ";") {
+			if pair = strings.TrimSpace(pair); pair != "" {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+
+	changed := false
+	for _, s := range c.settings {
+		if s.response {
+			continue
+		}
+
+		changed = true
+		switch s.act {
+		case HSA_ADD:
+			pairs = append(pairs, s.name+"="+s.value)
+		case HSA_DELETE:
+			a := pairs[:0]
+			for _, pair := range pairs {
+				if cookiePairName(pair) != s.name {
+					a = append(a, pair)
+				}
+			}
+
+			pairs = a
+		case HSA_MODIFY:
+			found := false
+			for i, pair := range pairs {
+				if cookiePairName(pair) == s.name {
+					pairs[i] = s.name + "=" + s.value
+					found = true
+				}
+			}
+
+			if !found {
+				pairs = append(pairs, s.name+"="+s.value)
+			}
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	if len(pairs) == 0 {
+		header.Del("Cookie")
+		return
+	}
+
+	header.Set("Cookie", strings.Join(pairs, "; "))
+}
+
+func cookiePairName(pair string) string {
+	if e := strings.IndexByte(pair, '='); e >= 0 {
+		pair = pair[:e]
+	}
+
+	return strings.TrimSpace(pair)
+}
+
+// ApplyResponse modifies Set-Cookie headers of response. Untouched
+// Set-Cookie keeps its original text.
+func (c *CookiesPolicy) ApplyResponse(header http.Header) {
+	lines := header["Set-Cookie"]
+	changed := false
+	for _, s := range c.settings {
+		if !s.response {
+			continue
+		}
+
+		changed = true
+		switch s.act {
+		case HSA_ADD:
+			lines = append(lines, s.cookie)
+		case HSA_DELETE:
+			a := make([]string, 0, len(lines))
+			for _, line := range lines {
+				if setCookieName(line) != s.name {
+					a = append(a, line)
+				}
+			}
+
+			lines = a
+		case HSA_MODIFY:
+			a := make([]string, 0, len(lines))
+			for _, line := range lines {
+				if setCookieName(line) == s.name {
+					line = s.modify(line)
+				}
+
+				a = append(a, line)
+			}
+
+			lines = a
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	if len(lines) > 0 {
+		header["Set-Cookie"] = lines
+	} else {
+		delete(header, "Set-Cookie")
+	}
+}
+
+func parseSetCookie(line string) *http.Cookie {
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": {line}}}).Cookies()
+	if len(cookies) > 0 {
+		return cookies[0]
+	} else {
+		return nil
+	}
+}
+
+func setCookieName(line string) string {
+	if cookie := parseSetCookie(line); cookie != nil {
+		return cookie.Name
+	} else {
+		return ""
+	}
+}
+
+func (s *cookieSetting) modify(line string) string {
+	cookie := parseSetCookie(line)
+	if cookie == nil {
+		return line
+	}
+
+	if s.expires != nil {
+		switch *s.expires {
+		case "session":
+			cookie.Expires = time.Time{}
+			cookie.MaxAge = 0
+		case "0":
+			cookie.Expires = time.Unix(0, 0)
+			cookie.MaxAge = -1
+		default:
+			seconds, _ := parseDuration(*s.expires)
+			d := time.Duration(float64(seconds) * float64(time.Second))
+			cookie.Expires = time.Now().Add(d)
+			cookie.MaxAge = int(d.Seconds())
+			if cookie.MaxAge <= 0 {
+				cookie.MaxAge = -1
+			}
+		}
+	}
+
+	if s.sameSite != nil {
+		cookie.SameSite = *s.sameSite
+	}
+
+	if s.secure != nil {
+		cookie.Secure = *s.secure
+	}
+
+	if s.httpOnly != nil {
+		cookie.HttpOnly = *s.httpOnly
+	}
+
+	c := cookie.String()
+	if len(c) == 0 {
+		return line
+	}
+
+	return c
+}
@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCookiesPolicy(t *testing.T) {
+	settings := strings.Join([]string{
+		"request +a=1",
+		"request -b",
+		"request sid=bad",
+		"response -track",
+		"response =session expires=0 samesite=strict secure nohttponly",
+		"response +vip=1; Path=/",
+	}, "\n")
+
+	cmd := "cookies " + url.QueryEscape(settings)
+	p, err := Factory(cmd)
+	if err != nil {
+		t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+		return
+	} else if p.Command() != cmd {
+		t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+	}
+
+	c, ok := p.(*CookiesPolicy)
+	if !ok {
+		t.Errorf(`Factory("%s") invalid class`, cmd)
+		return
+	}
+
+	req := http.Header{"Cookie": {"b=2; sid=good", "x=3"}}
+	c.ApplyRequest(req)
+	if v := req.Get("Cookie"); v != "sid=bad; x=3; a=1" {
+		t.Errorf(`ApplyRequest() Cookie: %s`, v)
+	}
+
+	req = http.Header{"Cookie": {`q="a b"; sid=good; bad,name=1; flag`}}
+	c.ApplyRequest(req)
+	if v := req.Get("Cookie"); v != `q="a b"; sid=bad; bad,name=1; flag; a=1` {
+		t.Errorf(`ApplyRequest() raw Cookie: %s`, v)
+	}
+
+	resp := http.Header{"Set-Cookie": {"track=1", "session=abc; Path=/; HttpOnly", "other=2; Path=/x"}}
+	c.ApplyResponse(resp)
+	lines := resp["Set-Cookie"]
+	if len(lines) != 3 {
+		t.Errorf(`ApplyResponse() Set-Cookie: %v`, lines)
+	} else {
+		if !strings.HasPrefix(lines[0], "session=abc; Path=/; Expires=Thu, 01 Jan 1970") ||
+			!strings.Contains(lines[0], "Max-Age=0; Secure; SameSite=Strict") ||
+			strings.Contains(lines[0], "HttpOnly") {
+			t.Errorf(`ApplyResponse() modified Set-Cookie: %s`, lines[0])
+		}
+
+		if lines[1] != "other=2; Path=/x" || lines[2] != "vip=1; Path=/" {
+			t.Errorf(`ApplyResponse() Set-Cookie: %v`, lines)
+		}
+	}
+
+	for _, s := range []string{"a=1", "request a", "response =a expires=x", "response =a unknown", "response +", "request -"} {
+		cmd := "cookies " + url.QueryEscape(s)
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
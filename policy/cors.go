@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const corsKeyword = "cors"
+
+const (
+	corsAllow = "allow"
+	corsStrip = "strip"
+)
+
+type CorsPolicy struct {
+	op      string
+	origins []string
+}
+
+type corsPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(corsPolicyFactory))
+}
+
+func (*corsPolicyFactory) Keyword() string {
+	return corsKeyword
+}
+
+func (*corsPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf(`%s need "%s [origins]" or "%s"`, corsKeyword, corsAllow, corsStrip)
+	}
+
+	switch args[0] {
+	case corsAllow:
+		// the last arg is the url-pattern, so origins must be followed by others
+		if len(args) >= 3 {
+			if origins, ok := parseOrigins(args[1]); ok {
+				return &CorsPolicy{corsAllow, origins}, args[2:], nil
+			}
+		}
+
+		return &CorsPolicy{corsAllow, nil}, args[1:], nil
+	case corsStrip:
+		return &CorsPolicy{corsStrip, nil}, args[1:], nil
+	default:
+		return nil, args, fmt.Errorf(`%s unknown op: %s`, corsKeyword, args[0])
+	}
+}
+
+// parseOrigins accepts "*" or origins like "https://a.com,http://b.com:8080".
+func parseOrigins(s string) ([]string, bool) {
+	if s == "*" {
+		return []string{s}, true
+	}
+
+	origins := strings.Split(s, ",")
+	for _, o := range origins {
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || len(u.Path) > 0 || len(u.RawQuery) > 0 {
+			return nil, false
+		}
+	}
+
+	return origins, true
+}
+
+func (p *CorsPolicy) Keyword() string {
+	return corsKeyword
+}
+
+func (p *CorsPolicy) Command() string {
+	if p.op == corsAllow && len(p.origins) > 0 {
+		return corsKeyword + " " + corsAllow + " " + strings.Join(p.origins, ",")
+	} else {
+		return corsKeyword + " " + p.op
+	}
+}
+
+func (p *CorsPolicy) Comment() string {
+	if p.op == corsStrip {
+		return "移除回复的 CORS 头"
+	} else if len(p.origins) > 0 && p.origins[0] != "*" {
+		return "允许 " + strings.Join(p.origins, "、") + " 跨域访问"
+	} else {
+		return "允许任意来源跨域访问"
+	}
+}
+
+func (p *CorsPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *CorsPolicy:
+		p.op = n.op
+		p.origins = n.origins
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *CorsPolicy) Allow() bool {
+	return p.op == corsAllow
+}
+
+func (p *CorsPolicy) Strip() bool {
+	return p.op == corsStrip
+}
+
+func (p *CorsPolicy) AllowOrigin(origin string) bool {
+	if p.op != corsAllow || len(origin) == 0 {
+		return false
+	} else if len(p.origins) == 0 {
+		return true
+	}
+
+	for _, o := range p.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+
+	return false
+}
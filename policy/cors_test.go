@@ -0,0 +1,40 @@
+package policy
+
+import "testing"
+
+func TestCorsPolicy(t *testing.T) {
+	check := func(cmd, target string, origins map[string]bool) {
+		u, err := FactoryUrl(cmd)
+		if err != nil {
+			t.Errorf(`FactoryUrl("%s") failed: %v`, cmd, err)
+			return
+		} else if u.Command() != cmd {
+			t.Errorf(`FactoryUrl("%s").Command() changed: %s`, cmd, u.Command())
+		} else if u.Target() != target {
+			t.Errorf(`FactoryUrl("%s").Target() wrong: %s`, cmd, u.Target())
+		}
+
+		c := u.Cors()
+		if c == nil {
+			t.Errorf(`FactoryUrl("%s") missed cors policy`, cmd)
+			return
+		}
+
+		for origin, allow := range origins {
+			if c.AllowOrigin(origin) != allow {
+				t.Errorf(`FactoryUrl("%s").AllowOrigin("%s") != %v`, cmd, origin, allow)
+			}
+		}
+	}
+
+	check("url cors allow g.cn/api/*", "g.cn/api/*", map[string]bool{"http://localhost:3000": true, "": false})
+	check("url cors allow * g.cn", "g.cn", map[string]bool{"http://localhost:3000": true})
+	check("url cors allow http://localhost:3000,https://a.com g.cn", "g.cn", map[string]bool{"http://localhost:3000": true, "https://a.com": true, "https://b.com": false})
+	check("url cors allow http://g.cn/api/*", "http://g.cn/api/*", map[string]bool{"https://b.com": true})
+	check("url cors strip g.cn", "g.cn", map[string]bool{"https://b.com": false})
+
+	cmd := "url cors g.cn"
+	if _, err := FactoryUrl(cmd); err == nil {
+		t.Errorf(`FactoryUrl("%s") should fail`, cmd)
+	}
+}
@@ -35,6 +35,7 @@ type baseDelayPolicy struct {
 	body     bool
 	rand     bool
 	duration float32
+	dist     delayDistribution
 }
 
 type DelayPolicy struct {
@@ -90,6 +91,22 @@ func (f *baseDelayPolicyFactory) Build(args []string) (Policy, []string, error)
 		return nil, args, fmt.Errorf("`%s` need a duration", f.keyword)
 	}
 
+	if isDelayDistribution(args[0]) {
+		if rand {
+			return nil, args, fmt.Errorf("`rand` can't be used with %s", args[0])
+		}
+
+		dist, rest, err := parseDelayDistribution(args)
+		if err != nil {
+			return nil, args, err
+		}
+
+		p := f.create()
+		p.(iBaseDelayPolicy).init(body, false, dist.typical())
+		p.(iBaseDelayPolicy).setDistribution(dist)
+		return p, rest, nil
+	}
+
 	duration, err := parseDuration(args[0])
 	if err != nil {
 		return nil, args, err
@@ -104,6 +121,7 @@ func (f *baseDelayPolicyFactory) Build(args []string) (Policy, []string, error)
 
 type iBaseDelayPolicy interface {
 	init(body, rand bool, duration float32)
+	setDistribution(dist delayDistribution)
 }
 
 func (p *baseDelayPolicy) init(body, rand bool, duration float32) {
@@ -112,8 +130,16 @@ func (p *baseDelayPolicy) init(body, rand bool, duration float32) {
 	p.duration = duration
 }
 
+func (p *baseDelayPolicy) setDistribution(dist delayDistribution) {
+	p.dist = dist
+}
+
 func (p *baseDelayPolicy) command() string {
 	c := formatDuration(p.duration)
+	if p.dist != nil {
+		c = p.dist.command()
+	}
+
 	if p.rand {
 		c = "rand " + c
 	}
@@ -127,7 +153,9 @@ func (p *baseDelayPolicy) command() string {
 
 func (p *baseDelayPolicy) comment() string {
 	c := formatDuration(p.duration)
-	if p.rand {
+	if p.dist != nil {
+		c = " " + p.dist.comment() + " "
+	} else if p.rand {
 		c = "随机 " + c + " "
 	} else {
 		c = " " + c + " "
@@ -144,22 +172,34 @@ func (p *baseDelayPolicy) Rand() bool {
 	return p.rand
 }
 
+// Distribution returns the name of the distribution, or "" if none.
+func (p *baseDelayPolicy) Distribution() string {
+	if p.dist == nil {
+		return ""
+	}
+
+	return strings.SplitN(p.dist.command(), " ", 2)[0]
+}
+
 func (p *baseDelayPolicy) Duration() time.Duration {
-	return (time.Duration)(p.duration * 1000000000)
+	return time.Duration(float64(p.duration) * float64(time.Second))
 }
 
 func (p *baseDelayPolicy) RandDuration(r *rand.Rand) time.Duration {
 	t := p.duration
-	if p.rand {
+	if p.dist != nil {
+		t = p.dist.sample(r)
+	} else if p.rand {
 		t *= r.Float32()
 	}
 
-	return (time.Duration)(t * 1000000000)
+	return time.Duration(float64(t) * float64(time.Second))
 }
 
 type baseDelayInterface interface {
 	Body() bool
 	Rand() bool
+	Distribution() string
 }
 
 func (d *DelayPolicy) Keyword() string {
@@ -186,9 +226,7 @@ func (d *DelayPolicy) Update(p Policy) error {
 
 	switch p := p.(type) {
 	case *DelayPolicy:
-		d.body = p.body
-		d.rand = p.rand
-		d.duration = p.duration
+		d.baseDelayPolicy = p.baseDelayPolicy
 	default:
 		return fmt.Errorf("unmatch policy")
 	}
@@ -220,9 +258,7 @@ func (d *TimeoutPolicy) Update(p Policy) error {
 
 	switch p := p.(type) {
 	case *TimeoutPolicy:
-		d.body = p.body
-		d.rand = p.rand
-		d.duration = p.duration
+		d.baseDelayPolicy = p.baseDelayPolicy
 	default:
 		return fmt.Errorf("unmatch policy")
 	}
@@ -254,9 +290,7 @@ func (d *DropPolicy) Update(p Policy) error {
 
 	switch p := p.(type) {
 	case *DropPolicy:
-		d.body = p.body
-		d.rand = p.rand
-		d.duration = p.duration
+		d.baseDelayPolicy = p.baseDelayPolicy
 	default:
 		return fmt.Errorf("unmatch policy")
 	}
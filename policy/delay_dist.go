@@ -0,0 +1,242 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	distNormal      = "normal"
+	distExponential = "exponential"
+	distPareto      = "pareto"
+	distPercentiles = "percentiles"
+)
+
+// delayDistribution gives random durations in seconds for delay policies.
+type delayDistribution interface {
+	sample(r *rand.Rand) float32
+	command() string
+	comment() string
+
+	// typical returns a representative duration, such as mean.
+	typical() float32
+}
+
+func isDelayDistribution(name string) bool {
+	switch name {
+	case distNormal, distExponential, distPareto, distPercentiles:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseDelayDistribution(args []string) (delayDistribution, []string, error) {
+	name := args[0]
+	need := 1
+	switch name {
+	case distNormal, distPareto:
+		need = 2
+	}
+
+	if len(args) < 1+need {
+		return nil, args, fmt.Errorf("%s need %d args", name, need)
+	}
+
+	v := args[1 : 1+need]
+	rest := args[1+need:]
+	if name == distPercentiles {
+		d, err := parsePercentilesDelay(v[0])
+		return d, rest, err
+	}
+
+	a, err := parseDuration(v[0])
+	if err != nil {
+		return nil, args, err
+	} else if a < 0 {
+		return nil, args, fmt.Errorf("%s negative duration: %s", name, v[0])
+	}
+
+	switch name {
+	case distNormal:
+		std, err := parseDuration(v[1])
+		if err != nil {
+			return nil, args, err
+		} else if std < 0 {
+			return nil, args, fmt.Errorf("%s negative stddev: %s", name, v[1])
+		}
+
+		return &normalDelay{a, std}, rest, nil
+	case distExponential:
+		return &exponentialDelay{a}, rest, nil
+	default:
+		alpha, err := strconv.ParseFloat(v[1], 32)
+		if err != nil {
+			return nil, args, err
+		} else if alpha <= 0 {
+			return nil, args, fmt.Errorf("%s alpha should be greater than 0: %s", name, v[1])
+		}
+
+		return &paretoDelay{a, float32(alpha)}, rest, nil
+	}
+}
+
+type normalDelay struct {
+	mean   float32
+	stddev float32
+}
+
+func (d *normalDelay) sample(r *rand.Rand) float32 {
+	t := d.mean + float32(r.NormFloat64())*d.stddev
+	if t < 0 {
+		return 0
+	}
+
+	return t
+}
+
+func (d *normalDelay) command() string {
+	return distNormal + " " + formatDuration(d.mean) + " " + formatDuration(d.stddev)
+}
+
+func (d *normalDelay) comment() string {
+	return "正态分布（均值 " + formatDuration(d.mean) + "，标准差 " + formatDuration(d.stddev) + "）"
+}
+
+func (d *normalDelay) typical() float32 {
+	return d.mean
+}
+
+type exponentialDelay struct {
+	mean float32
+}
+
+func (d *exponentialDelay) sample(r *rand.Rand) float32 {
+	return float32(r.ExpFloat64()) * d.mean
+}
+
+func (d *exponentialDelay) command() string {
+	return distExponential + " " + formatDuration(d.mean)
+}
+
+func (d *exponentialDelay) comment() string {
+	return "指数分布（均值 " + formatDuration(d.mean) + "）"
+}
+
+func (d *exponentialDelay) typical() float32 {
+	return d.mean
+}
+
+// maxParetoDelay bounds pareto samples in seconds, as a small alpha gives
+// an almost unbounded tail.
+const maxParetoDelay = 3600
+
+type paretoDelay struct {
+	min   float32
+	alpha float32
+}
+
+func (d *paretoDelay) sample(r *rand.Rand) float32 {
+	u := 1 - r.Float64() // (0, 1]
+	x := float64(d.min) / math.Pow(u, 1/float64(d.alpha))
+	return float32(math.Min(x, math.Max(float64(d.min), maxParetoDelay)))
+}
+
+func (d *paretoDelay) command() string {
+	return distPareto + " " + formatDuration(d.min) + " " + strconv.FormatFloat(float64(d.alpha), 'f', -1, 32)
+}
+
+func (d *paretoDelay) comment() string {
+	return "帕累托分布（最小 " + formatDuration(d.min) + "，alpha " + strconv.FormatFloat(float64(d.alpha), 'f', -1, 32) + "）"
+}
+
+func (d *paretoDelay) typical() float32 {
+	return d.min
+}
+
+type percentile struct {
+	p        float32 // (0, 100]
+	duration float32
+}
+
+// percentilesDelay interpolates linearly between percentiles, below the
+// first it's the first duration, and above the last it's the last.
+type percentilesDelay struct {
+	points []percentile
+}
+
+func parsePercentilesDelay(s string) (*percentilesDelay, error) {
+	d := &percentilesDelay{make([]percentile, 0)}
+	for _, v := range strings.Split(s, ",") {
+		e := strings.Index(v, "=")
+		if e < 0 || !strings.HasPrefix(v, "p") {
+			return nil, fmt.Errorf("%s invalid: %s", distPercentiles, v)
+		}
+
+		p, err := strconv.ParseFloat(v[1:e], 32)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("%s invalid percentile: %s", distPercentiles, v)
+		}
+
+		t, err := parseDuration(v[e+1:])
+		if err != nil || t < 0 {
+			return nil, fmt.Errorf("%s invalid duration: %s", distPercentiles, v)
+		}
+
+		d.points = append(d.points, percentile{float32(p), t})
+	}
+
+	sort.Slice(d.points, func(i, j int) bool { return d.points[i].p < d.points[j].p })
+	for i := 1; i < len(d.points); i++ {
+		if d.points[i].p == d.points[i-1].p {
+			return nil, fmt.Errorf("%s duplicated p%v", distPercentiles, d.points[i].p)
+		} else if d.points[i].duration < d.points[i-1].duration {
+			return nil, fmt.Errorf("%s durations should grow with percentile", distPercentiles)
+		}
+	}
+
+	return d, nil
+}
+
+func (d *percentilesDelay) sample(r *rand.Rand) float32 {
+	u := r.Float32() * 100
+	last := d.points[0]
+	if u <= last.p {
+		return last.duration
+	}
+
+	for _, p := range d.points[1:] {
+		if u <= p.p {
+			return last.duration + (p.duration-last.duration)*(u-last.p)/(p.p-last.p)
+		}
+
+		last = p
+	}
+
+	return last.duration
+}
+
+func (d *percentilesDelay) format(sep string) string {
+	s := make([]string, len(d.points))
+	for i, p := range d.points {
+		s[i] = "p" + strconv.FormatFloat(float64(p.p), 'f', -1, 32) + sep + formatDuration(p.duration)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (d *percentilesDelay) command() string {
+	return distPercentiles + " " + d.format("=")
+}
+
+func (d *percentilesDelay) comment() string {
+	return "百分位分布（" + d.format(" ") + "）"
+}
+
+func (d *percentilesDelay) typical() float32 {
+	return d.points[len(d.points)-1].duration
+}
@@ -1,6 +1,10 @@
 package policy
 
-import "testing"
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
 
 func TestDelayPolicy(t *testing.T) {
 	cmd := "delay 1s"
@@ -66,3 +70,68 @@ func TestDelayPolicy(t *testing.T) {
 		}
 	}
 }
+
+func TestDelayDistribution(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	check := func(cmd, dist string, min, max time.Duration) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() failed: "%s"`, cmd, p.Command())
+		}
+
+		d, ok := p.(baseDelayInterface)
+		if !ok || d.Distribution() != dist {
+			t.Errorf(`Factory("%s") distribution wrong: %v`, cmd, p)
+			return
+		}
+
+		for i := 0; i < 1000; i++ {
+			x := p.(delayInterface).RandDuration(r)
+			if x < min || x > max {
+				t.Errorf(`Factory("%s").RandDuration() out of [%v, %v]: %v`, cmd, min, max, x)
+				return
+			}
+		}
+	}
+
+	check("delay normal 100ms 10ms", distNormal, 0, time.Second)
+	check("delay body exponential 200ms", distExponential, 0, 10*time.Second)
+	check("timeout pareto 50ms 1.5", distPareto, 50*time.Millisecond, time.Hour)
+	check("delay pareto 10ms 0.01", distPareto, 10*time.Millisecond, time.Hour)
+	check("delay percentiles p50=100ms,p90=500ms,p99.9=2s", distPercentiles, 100*time.Millisecond, 2*time.Second)
+
+	cmd := "domain delay exponential 10ms g.cn"
+	if p, err := Factory(cmd); err != nil {
+		t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+	} else if p.Command() != cmd {
+		t.Errorf(`Factory("%s").Command() failed: "%s"`, cmd, p.Command())
+	} else if d := p.(*DomainPolicy).Delay(); d == nil || d.Distribution() != distExponential {
+		t.Errorf(`Factory("%s").Delay() wrong: %v`, cmd, d)
+	}
+
+	p, _ := Factory("delay percentiles p99=1s,p50=100ms")
+	if p == nil || p.Command() != "delay percentiles p50=100ms,p99=1s" {
+		t.Errorf(`percentiles not sorted: %v`, p)
+	}
+
+	bad := func(cmd string) {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+
+	bad("delay normal 100ms")
+	bad("delay rand normal 100ms 10ms")
+	bad("delay pareto 10ms 0")
+	bad("delay percentiles p50=1s,p90=100ms")
+	bad("delay percentiles p200=1s")
+	bad("delay percentiles 50=1s")
+	bad("domain delay body normal 10ms 1ms g.cn")
+}
+
+type delayInterface interface {
+	RandDuration(r *rand.Rand) time.Duration
+}
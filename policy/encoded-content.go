@@ -17,3 +17,13 @@ func decodeContent(content string) ([]byte, error) {
 		return []byte(r), nil
 	}
 }
+
+func checkEncodedTemplate(content string) error {
+	c, err := decodeContent(content)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseContentTemplate(string(c))
+	return err
+}
@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"fmt"
+)
+
+const encodingKeyword = "encoding"
+
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingBrotli   = "br"
+)
+
+// EncodingPolicy re-encodes the response body, and asks upstream for it.
+type EncodingPolicy struct {
+	stringPolicy
+}
+
+func init() {
+	regFactory(newStringPolicyFactory(encodingKeyword, "encoding", func(val string) (Policy, error) {
+		switch val {
+		case EncodingIdentity, EncodingGzip, EncodingDeflate, EncodingBrotli:
+		default:
+			return nil, fmt.Errorf("%s unknown: %s, should be identity|gzip|deflate|br", encodingKeyword, val)
+		}
+
+		return &EncodingPolicy{stringPolicy{encodingKeyword, val, func(val string) string {
+			if val == EncodingIdentity {
+				return "回复内容不压缩"
+			}
+
+			return "回复内容以 " + val + " 压缩"
+		}}}, nil
+	}))
+}
+
+func (p *EncodingPolicy) Encoding() string {
+	return p.str
+}
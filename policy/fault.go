@@ -0,0 +1,258 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cutKeyword     = "cut"
+	corruptKeyword = "corrupt"
+	stallKeyword   = "stall"
+)
+
+// faultSize is a count of bytes, or a percent of the whole body.
+type faultSize struct {
+	bytes   int64
+	percent float32 // (0, 100], 0 means bytes
+}
+
+func parseFaultSize(s string) (faultSize, error) {
+	if strings.HasSuffix(s, "%") {
+		f, err := strconv.ParseFloat(s[:len(s)-1], 32)
+		if err != nil {
+			return faultSize{}, err
+		} else if f < 0 || f > 100 {
+			return faultSize{}, fmt.Errorf("percent out of [0, 100]: %s", s)
+		}
+
+		if f == 0 {
+			return faultSize{}, nil
+		}
+
+		return faultSize{0, float32(f)}, nil
+	}
+
+	speed, err := parseSpeed(s)
+	if err != nil {
+		return faultSize{}, err
+	} else if speed < 0 {
+		return faultSize{}, fmt.Errorf("negative size: %s", s)
+	}
+
+	return faultSize{int64(speed), 0}, nil
+}
+
+func (s faultSize) String() string {
+	if s.percent > 0 {
+		return strconv.FormatFloat(float64(s.percent), 'f', -1, 32) + "%"
+	}
+
+	if s.bytes >= 1024*1024 && s.bytes%(1024*1024) == 0 {
+		return strconv.FormatInt(s.bytes/1024/1024, 10) + "MB"
+	} else if s.bytes >= 1024 && s.bytes%1024 == 0 {
+		return strconv.FormatInt(s.bytes/1024, 10) + "KB"
+	}
+
+	return strconv.FormatInt(s.bytes, 10)
+}
+
+// offset returns the bytes of s in total, or -1 if total is unknown.
+func (s faultSize) offset(total int64) int64 {
+	if s.percent <= 0 {
+		return s.bytes
+	} else if total < 0 {
+		return -1
+	}
+
+	return int64(float64(total) * float64(s.percent) / 100)
+}
+
+// CutPolicy resets the connection after some bytes of body.
+type CutPolicy struct {
+	size faultSize
+}
+
+// CorruptPolicy flips random bytes of body.
+type CorruptPolicy struct {
+	rate float32
+}
+
+// StallPolicy pauses the body at an offset for a while.
+type StallPolicy struct {
+	offset   faultSize
+	duration float32
+}
+
+func init() {
+	regFactory(new(cutPolicyFactory))
+	regFactory(new(corruptPolicyFactory))
+	regFactory(new(stallPolicyFactory))
+}
+
+type cutPolicyFactory struct {
+}
+
+func (*cutPolicyFactory) Keyword() string {
+	return cutKeyword
+}
+
+func (*cutPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf("%s need <bytes> or <percent>%%", cutKeyword)
+	}
+
+	size, err := parseFaultSize(args[0])
+	if err != nil {
+		return nil, args, fmt.Errorf("%s invalid size: %v", cutKeyword, err)
+	}
+
+	return &CutPolicy{size}, args[1:], nil
+}
+
+func (p *CutPolicy) Keyword() string {
+	return cutKeyword
+}
+
+func (p *CutPolicy) Command() string {
+	return cutKeyword + " " + p.size.String()
+}
+
+func (p *CutPolicy) Comment() string {
+	if p.size.percent > 0 {
+		return "回复内容传输 " + p.size.String() + " 后断开连接"
+	}
+
+	return "回复内容传输 " + p.size.String() + " 字节后断开连接"
+}
+
+func (p *CutPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *CutPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+// Offset returns bytes to send before cut, -1 if it's a percent but total
+// is unknown (< 0).
+func (p *CutPolicy) Offset(total int64) int64 {
+	return p.size.offset(total)
+}
+
+func (p *CutPolicy) Percent() bool {
+	return p.size.percent > 0
+}
+
+type corruptPolicyFactory struct {
+}
+
+func (*corruptPolicyFactory) Keyword() string {
+	return corruptKeyword
+}
+
+func (*corruptPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf("%s need a rate", corruptKeyword)
+	}
+
+	rate, err := parseRate(args[0])
+	if err != nil {
+		return nil, args, fmt.Errorf("%s invalid rate: %v", corruptKeyword, err)
+	}
+
+	return &CorruptPolicy{rate}, args[1:], nil
+}
+
+func (p *CorruptPolicy) Keyword() string {
+	return corruptKeyword
+}
+
+func (p *CorruptPolicy) Command() string {
+	return corruptKeyword + " " + formatRate(p.rate)
+}
+
+func (p *CorruptPolicy) Comment() string {
+	return "以 " + formatRate(p.rate) + " 的比率篡改回复内容的字节"
+}
+
+func (p *CorruptPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *CorruptPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *CorruptPolicy) Rate() float32 {
+	return p.rate
+}
+
+type stallPolicyFactory struct {
+}
+
+func (*stallPolicyFactory) Keyword() string {
+	return stallKeyword
+}
+
+func (*stallPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) < 2 {
+		return nil, args, fmt.Errorf("%s need <offset> <duration>", stallKeyword)
+	}
+
+	offset, err := parseFaultSize(args[0])
+	if err != nil {
+		return nil, args, fmt.Errorf("%s invalid offset: %v", stallKeyword, err)
+	}
+
+	duration, err := parseDuration(args[1])
+	if err != nil {
+		return nil, args, fmt.Errorf("%s invalid duration: %v", stallKeyword, err)
+	}
+
+	return &StallPolicy{offset, duration}, args[2:], nil
+}
+
+func (p *StallPolicy) Keyword() string {
+	return stallKeyword
+}
+
+func (p *StallPolicy) Command() string {
+	return stallKeyword + " " + p.offset.String() + " " + formatDuration(p.duration)
+}
+
+func (p *StallPolicy) Comment() string {
+	return "回复内容传输至 " + p.offset.String() + " 处暂停 " + formatDuration(p.duration)
+}
+
+func (p *StallPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *StallPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+// Offset is like CutPolicy.Offset.
+func (p *StallPolicy) Offset(total int64) int64 {
+	return p.offset.offset(total)
+}
+
+func (p *StallPolicy) Percent() bool {
+	return p.offset.percent > 0
+}
+
+func (p *StallPolicy) Duration() time.Duration {
+	return time.Duration(float64(p.duration) * float64(time.Second))
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+)
+
+const hangKeyword = "hang"
+
+// HangPolicy holds the connection without response, until duration passes
+// or the profile restarts.
+type HangPolicy struct {
+	partial  bool
+	duration float32 // 0 for no limit
+}
+
+type hangPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(hangPolicyFactory))
+}
+
+func (*hangPolicyFactory) Keyword() string {
+	return hangKeyword
+}
+
+func (*hangPolicyFactory) Build(args []string) (Policy, []string, error) {
+	p := &HangPolicy{}
+	if len(args) > 0 && args[0] == "partial" {
+		p.partial = true
+		args = args[1:]
+	}
+
+	if len(args) > 0 {
+		if d, err := parseDuration(args[0]); err == nil {
+			if d < 0 {
+				return nil, args, fmt.Errorf("%s negative duration: %s", hangKeyword, args[0])
+			}
+
+			p.duration = d
+			args = args[1:]
+		}
+	}
+
+	return p, args, nil
+}
+
+func (p *HangPolicy) Keyword() string {
+	return hangKeyword
+}
+
+func (p *HangPolicy) Command() string {
+	c := hangKeyword
+	if p.partial {
+		c += " partial"
+	}
+
+	if p.duration > 0 {
+		c += " " + formatDuration(p.duration)
+	}
+
+	return c
+}
+
+func (p *HangPolicy) Comment() string {
+	c := "挂起连接不回复"
+	if p.partial {
+		c = "只回复部分 headers 后挂起连接"
+	}
+
+	if p.duration > 0 {
+		c += " " + formatDuration(p.duration) + " 后断开"
+	} else {
+		c += "，直到重新开始"
+	}
+
+	return c
+}
+
+func (p *HangPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *HangPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *HangPolicy) Partial() bool {
+	return p.partial
+}
+
+// Duration returns 0 if hang until restart.
+func (p *HangPolicy) Duration() time.Duration {
+	return time.Duration(float64(p.duration) * float64(time.Second))
+}
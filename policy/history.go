@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const historyKeyword = "history"
+
+// HistoryPolicy limits histories of a device kept in memory, 0 means no
+// limit. Older histories are spilled to disk, or dropped if evict.
+type HistoryPolicy struct {
+	count int
+	size  int64
+	evict bool
+}
+
+type historyPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(historyPolicyFactory))
+}
+
+func (*historyPolicyFactory) Keyword() string {
+	return historyKeyword
+}
+
+func (*historyPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) > 0 && args[0] == "off" {
+		return &HistoryPolicy{}, args[1:], nil
+	}
+
+	p := &HistoryPolicy{}
+	rest := args
+	for len(rest) > 0 {
+		if rest[0] == "evict" {
+			p.evict = true
+			rest = rest[1:]
+			continue
+		} else if len(rest) < 2 {
+			break
+		}
+
+		switch rest[0] {
+		case "count":
+			n, err := strconv.Atoi(rest[1])
+			if err != nil || n <= 0 {
+				return nil, args, fmt.Errorf(`%s invalid count: %s`, historyKeyword, rest[1])
+			}
+
+			p.count = n
+		case "size":
+			s, err := parseFaultSize(rest[1])
+			if err != nil || s.percent > 0 || s.bytes <= 0 {
+				return nil, args, fmt.Errorf(`%s invalid size: %s`, historyKeyword, rest[1])
+			}
+
+			p.size = s.bytes
+		default:
+			return nil, args, fmt.Errorf(`%s unknown arg: %s`, historyKeyword, rest[0])
+		}
+
+		rest = rest[2:]
+	}
+
+	if len(rest) > 0 {
+		return nil, args, fmt.Errorf(`%s unknown arg: %s`, historyKeyword, rest[0])
+	} else if p.Off() {
+		return nil, args, fmt.Errorf(`%s need ([count <n>] [size <size>] [evict]|off)`, historyKeyword)
+	}
+
+	return p, rest, nil
+}
+
+func (p *HistoryPolicy) Keyword() string {
+	return historyKeyword
+}
+
+func (p *HistoryPolicy) Command() string {
+	if p.Off() {
+		return historyKeyword + " off"
+	}
+
+	c := []string{historyKeyword}
+	if p.count > 0 {
+		c = append(c, "count", strconv.Itoa(p.count))
+	}
+
+	if p.size > 0 {
+		c = append(c, "size", faultSize{p.size, 0}.String())
+	}
+
+	if p.evict {
+		c = append(c, "evict")
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *HistoryPolicy) Comment() string {
+	if p.Off() {
+		return "历史按全局限制"
+	}
+
+	c := make([]string, 0, 2)
+	if p.count > 0 {
+		c = append(c, strconv.Itoa(p.count)+" 条")
+	}
+
+	if p.size > 0 {
+		c = append(c, faultSize{p.size, 0}.String())
+	}
+
+	s := "内存中历史最多 " + strings.Join(c, "、")
+	if p.evict {
+		return s + "，超出的旧历史丢弃"
+	}
+
+	return s + "，超出的旧历史转存磁盘"
+}
+
+func (p *HistoryPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *HistoryPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *HistoryPolicy) Off() bool {
+	return p.count <= 0 && p.size <= 0
+}
+
+func (p *HistoryPolicy) Count() int {
+	return p.count
+}
+
+func (p *HistoryPolicy) Size() int64 {
+	return p.size
+}
+
+func (p *HistoryPolicy) Evict() bool {
+	return p.evict
+}
@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestHistoryPolicy(t *testing.T) {
+	check := func(cmd, command string, count int, size int64, evict bool) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != command {
+			t.Errorf(`Factory("%s").Command() wrong: %s vs %s`, cmd, p.Command(), command)
+		}
+
+		h, ok := p.(*HistoryPolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+		} else if h.Count() != count || h.Size() != size || h.Evict() != evict {
+			t.Errorf(`Factory("%s") wrong: %d %d %v`, cmd, h.Count(), h.Size(), h.Evict())
+		}
+	}
+
+	check("history off", "history off", 0, 0, false)
+	check("history count 100", "history count 100", 100, 0, false)
+	check("history size 64mb count 10", "history count 10 size 64MB", 10, 64*1024*1024, false)
+	check("history evict size 1000", "history size 1000 evict", 0, 1000, true)
+
+	for _, cmd := range []string{"history", "history evict", "history count 0", "history count x", "history size 10%", "history count 1 more"} {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
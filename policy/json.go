@@ -0,0 +1,291 @@
+package policy
+
+import (
+	"github.com/benbearchen/asuran/util/cmd"
+
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	jsonSetKeyword    = "json-set"
+	jsonDeleteKeyword = "json-delete"
+	jsonPatchKeyword  = "json-patch"
+)
+
+// JsonEditor edits a JSON document decoded with json.Number, and returns
+// the new document and the errors of edits which were skipped.
+type JsonEditor interface {
+	Policy
+	EditJson(doc interface{}) (interface{}, []error)
+}
+
+type jsonSetter struct {
+	path  string
+	nodes []jsonPathNode
+	value interface{}
+	raw   string
+}
+
+type JsonSetPolicy struct {
+	setters []jsonSetter
+}
+
+type JsonDeletePolicy struct {
+	paths [][]jsonPathNode
+	raws  []string
+}
+
+type JsonPatchPolicy struct {
+	stringPolicy
+	ops []jsonPatchOp
+}
+
+type jsonSetPolicyFactory struct {
+}
+
+type jsonDeletePolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(jsonSetPolicyFactory))
+	regFactory(new(jsonDeletePolicyFactory))
+	regFactory(newStringPolicyFactory(jsonPatchKeyword, "url encoded json patch", func(content string) (Policy, error) {
+		return newJsonPatchPolicy(content)
+	}))
+}
+
+func newJsonPatchPolicy(content string) (*JsonPatchPolicy, error) {
+	c, err := decodeContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := parseJsonPatch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JsonPatchPolicy{stringPolicy{jsonPatchKeyword, content, func(string) string {
+		return fmt.Sprintf("以 JSON Patch 修改返回内容（%d 项）", len(ops))
+	}}, ops}, nil
+}
+
+// takeJsonPaths takes args as paths until one doesn't start with `$',
+// as the last arg of url is the url-pattern.
+func takeJsonPaths(keyword string, args []string) ([]string, []string, error) {
+	paths := make([]string, 0)
+	for len(args) > 0 && strings.HasPrefix(args[0], "$") {
+		paths = append(paths, args[0])
+		args = args[1:]
+	}
+
+	if len(paths) == 0 {
+		return nil, args, fmt.Errorf(`%s need json path(s) like "$.a.b"`, keyword)
+	}
+
+	return paths, args, nil
+}
+
+func (*jsonSetPolicyFactory) Keyword() string {
+	return jsonSetKeyword
+}
+
+func (*jsonSetPolicyFactory) Build(args []string) (Policy, []string, error) {
+	sets, rest, err := takeJsonPaths(jsonSetKeyword, args)
+	if err != nil {
+		return nil, args, err
+	}
+
+	setters := make([]jsonSetter, 0, len(sets))
+	for _, s := range sets {
+		e := strings.IndexByte(s, '=')
+		if e < 0 {
+			return nil, args, fmt.Errorf(`%s "%s" should like <path>=<json-value>`, jsonSetKeyword, s)
+		}
+
+		nodes, err := parseJsonPath(s[:e])
+		if err != nil {
+			return nil, args, err
+		}
+
+		v, err := decodeJson([]byte(s[e+1:]))
+		if err != nil {
+			return nil, args, fmt.Errorf(`%s "%s" has invalid json value: %v`, jsonSetKeyword, s, err)
+		}
+
+		setters = append(setters, jsonSetter{s[:e], nodes, v, s[e+1:]})
+	}
+
+	return &JsonSetPolicy{setters}, rest, nil
+}
+
+func (p *JsonSetPolicy) Keyword() string {
+	return jsonSetKeyword
+}
+
+func (p *JsonSetPolicy) Command() string {
+	c := make([]string, 0, 1+len(p.setters))
+	c = append(c, jsonSetKeyword)
+	for _, s := range p.setters {
+		c = append(c, cmd.Quote(s.path+"="+s.raw))
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *JsonSetPolicy) Comment() string {
+	c := make([]string, 0, len(p.setters))
+	for _, s := range p.setters {
+		c = append(c, s.path+" = "+s.raw)
+	}
+
+	return "修改返回 JSON：" + strings.Join(c, "，")
+}
+
+func (p *JsonSetPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *JsonSetPolicy:
+		p.setters = n.setters
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *JsonSetPolicy) EditJson(doc interface{}) (interface{}, []error) {
+	var errs []error
+	for _, s := range p.setters {
+		d, err := jsonSet(doc, s.nodes, s.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %v", jsonSetKeyword, s.path, err))
+		} else {
+			doc = d
+		}
+	}
+
+	return doc, errs
+}
+
+func (*jsonDeletePolicyFactory) Keyword() string {
+	return jsonDeleteKeyword
+}
+
+func (*jsonDeletePolicyFactory) Build(args []string) (Policy, []string, error) {
+	raws, rest, err := takeJsonPaths(jsonDeleteKeyword, args)
+	if err != nil {
+		return nil, args, err
+	}
+
+	paths := make([][]jsonPathNode, 0, len(raws))
+	for _, r := range raws {
+		nodes, err := parseJsonPath(r)
+		if err != nil {
+			return nil, args, err
+		} else if len(nodes) == 0 {
+			return nil, args, fmt.Errorf(`%s can't delete the root "$"`, jsonDeleteKeyword)
+		}
+
+		paths = append(paths, nodes)
+	}
+
+	return &JsonDeletePolicy{paths, raws}, rest, nil
+}
+
+func (p *JsonDeletePolicy) Keyword() string {
+	return jsonDeleteKeyword
+}
+
+func (p *JsonDeletePolicy) Command() string {
+	c := make([]string, 0, 1+len(p.raws))
+	c = append(c, jsonDeleteKeyword)
+	for _, r := range p.raws {
+		c = append(c, cmd.Quote(r))
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *JsonDeletePolicy) Comment() string {
+	return "删除返回 JSON 的 " + strings.Join(p.raws, "，")
+}
+
+func (p *JsonDeletePolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *JsonDeletePolicy:
+		p.paths = n.paths
+		p.raws = n.raws
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *JsonDeletePolicy) EditJson(doc interface{}) (interface{}, []error) {
+	var errs []error
+	for i, nodes := range p.paths {
+		d, err := jsonDelete(doc, nodes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %v", jsonDeleteKeyword, p.raws[i], err))
+		} else {
+			doc = d
+		}
+	}
+
+	return doc, errs
+}
+
+func (p *JsonPatchPolicy) EditJson(doc interface{}) (interface{}, []error) {
+	// patch should be applied entirely or not at all
+	c, err := encodeJson(doc)
+	if err != nil {
+		return doc, []error{err}
+	}
+
+	copied, err := decodeJson(c)
+	if err != nil {
+		return doc, []error{err}
+	}
+
+	d, err := applyJsonPatch(copied, p.ops)
+	if err != nil {
+		return doc, []error{err}
+	}
+
+	return d, nil
+}
+
+// EditJsonContent decodes content as JSON, edits it by editors in order,
+// then encodes it back. The content keeps unchanged, in key order and
+// number format, if it's not JSON or no edit applies.
+func EditJsonContent(content []byte, editors []JsonEditor) ([]byte, []error) {
+	doc, err := decodeJson(content)
+	if err != nil {
+		return content, []error{fmt.Errorf("not json content: %v", err)}
+	}
+
+	// editors may change doc in place
+	original, err := encodeJson(doc)
+	if err != nil {
+		return content, []error{err}
+	}
+
+	var errs []error
+	for _, e := range editors {
+		var es []error
+		doc, es = e.EditJson(doc)
+		errs = append(errs, es...)
+	}
+
+	c, err := encodeJson(doc)
+	if err != nil {
+		return content, append(errs, err)
+	} else if bytes.Equal(c, original) {
+		return content, errs
+	}
+
+	return c, errs
+}
@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestJsonSetDeletePolicy(t *testing.T) {
+	check := func(cmd, content, target string, errs int) {
+		u, err := FactoryUrl(cmd)
+		if err != nil {
+			t.Errorf(`FactoryUrl("%s") failed: %v`, cmd, err)
+			return
+		} else if u.Command() != cmd {
+			t.Errorf(`FactoryUrl("%s").Command() changed: %s`, cmd, u.Command())
+		}
+
+		c, es := EditJsonContent([]byte(content), u.JsonEditors())
+		if string(c) != target {
+			t.Errorf(`"%s" edit %s => %s != %s`, cmd, content, string(c), target)
+		}
+
+		if len(es) != errs {
+			t.Errorf(`"%s" edit %s errors: %v`, cmd, content, es)
+		}
+	}
+
+	check("url json-set $.data.user.vip=true g.cn",
+		`{"data":{"user":{"vip":false,"id":12345678901234567890}}}`,
+		`{"data":{"user":{"id":12345678901234567890,"vip":true}}}`, 0)
+	check("url json-set $.a[1]=\"x\" $.b={\"c\":null} g.cn",
+		`{"a":[1,2,3]}`, `{"a":[1,"x",3],"b":{"c":null}}`, 0)
+	check("url json-set `$[\"a b\"]=\"<c d>\"` g.cn",
+		`{}`, `{"a b":"<c d>"}`, 0)
+	check("url json-set $.x.y=1 $.z=2 g.cn",
+		`{}`, `{"z":2}`, 1)
+	check("url json-set $.a=1 g.cn",
+		`not json`, `not json`, 1)
+	check("url json-set $.x.y=1 g.cn",
+		`{"b": 1.0, "a": [2]}`, `{"b": 1.0, "a": [2]}`, 1)
+	check("url json-delete $.c g.cn",
+		`{"b": 1.0, "a": [2]}`, `{"b": 1.0, "a": [2]}`, 1)
+	check("url json-set $.b=1 g.cn",
+		`{"b": 1, "a": 2}`, `{"b": 1, "a": 2}`, 0)
+	check("url json-delete $.a[0] $.b g.cn",
+		`{"a":[1,2],"b":3,"c":4}`, `{"a":[2],"c":4}`, 0)
+	check("url json-delete $.x g.cn",
+		`{"a":1}`, `{"a":1}`, 1)
+	check("url json-set $.a=2 json-delete $.b g.cn",
+		`{"a":1,"b":3}`, `{"a":2}`, 0)
+
+	for _, cmd := range []string{
+		"url json-set g.cn",
+		"url json-set $.a g.cn",
+		"url json-set $.a=x g.cn",
+		"url json-set $..a=1 g.cn",
+		"url json-delete $ g.cn",
+		"url json-delete $[a] g.cn",
+	} {
+		if _, err := FactoryUrl(cmd); err == nil {
+			t.Errorf(`FactoryUrl("%s") should fail`, cmd)
+		}
+	}
+}
+
+func TestJsonPatchPolicy(t *testing.T) {
+	check := func(patch, content, target string, errs int) {
+		cmd := "json-patch " + url.QueryEscape(patch)
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+		}
+
+		c, es := EditJsonContent([]byte(content), []JsonEditor{p.(*JsonPatchPolicy)})
+		if string(c) != target {
+			t.Errorf(`patch %s to %s => %s != %s`, patch, content, string(c), target)
+		}
+
+		if len(es) != errs {
+			t.Errorf(`patch %s to %s errors: %v`, patch, content, es)
+		}
+	}
+
+	check(`[{"op":"replace","path":"/a/b","value":2}]`, `{"a":{"b":1}}`, `{"a":{"b":2}}`, 0)
+	check(`[{"op":"add","path":"/a/1","value":9}]`, `{"a":[1,2]}`, `{"a":[1,9,2]}`, 0)
+	check(`[{"op":"add","path":"/a/-","value":9}]`, `{"a":[1,2]}`, `{"a":[1,2,9]}`, 0)
+	check(`[{"op":"remove","path":"/a~1b"}]`, `{"a/b":1,"c":2}`, `{"c":2}`, 0)
+	check(`[{"op":"move","from":"/a","path":"/b"}]`, `{"a":1}`, `{"b":1}`, 0)
+	check(`[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":[1]}`, `{"a":[1],"b":[1]}`, 0)
+	check(`[{"op":"test","path":"/a","value":1},{"op":"remove","path":"/a"}]`, `{"a":1}`, `{}`, 0)
+	check(`[{"op":"remove","path":"/a"},{"op":"test","path":"/b","value":1}]`, `{"a":1,"b":2}`, `{"a":1,"b":2}`, 1)
+	check(`[{"op":"replace","path":"/x","value":1}]`, `{"a":1}`, `{"a":1}`, 1)
+
+	for _, patch := range []string{`{}`, `[{"op":"x","path":"/a"}]`, `[{"op":"add","path":"/a"}]`, `[{"op":"remove","path":"a"}]`} {
+		cmd := "json-patch " + url.QueryEscape(patch)
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
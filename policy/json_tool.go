@@ -0,0 +1,454 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathNode is a key of object, or an index of array if key is empty.
+type jsonPathNode struct {
+	key   string
+	index int
+	isKey bool
+}
+
+func (n jsonPathNode) String() string {
+	if n.isKey {
+		return "." + n.key
+	} else {
+		return "[" + strconv.Itoa(n.index) + "]"
+	}
+}
+
+// parseJsonPath supports a simple subset of JSONPath, like $.a[0]["b"]['c'].
+func parseJsonPath(path string) ([]jsonPathNode, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf(`json path "%s" should start with "$"`, path)
+	}
+
+	nodes := make([]jsonPathNode, 0)
+	p := path[1:]
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			e := strings.IndexAny(p[1:], ".[")
+			if e < 0 {
+				e = len(p) - 1
+			}
+
+			key := p[1 : e+1]
+			if len(key) == 0 {
+				return nil, fmt.Errorf(`json path "%s" has empty key`, path)
+			}
+
+			nodes = append(nodes, jsonPathNode{key: key, isKey: true})
+			p = p[e+1:]
+		case '[':
+			e := strings.IndexByte(p, ']')
+			if e < 0 {
+				return nil, fmt.Errorf(`json path "%s" missed "]"`, path)
+			}
+
+			s := p[1:e]
+			if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+				nodes = append(nodes, jsonPathNode{key: s[1 : len(s)-1], isKey: true})
+			} else {
+				i, err := strconv.Atoi(s)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf(`json path "%s" has invalid index "%s"`, path, s)
+				}
+
+				nodes = append(nodes, jsonPathNode{index: i})
+			}
+
+			p = p[e+1:]
+		default:
+			return nil, fmt.Errorf(`json path "%s" is invalid at "%s"`, path, p)
+		}
+	}
+
+	return nodes, nil
+}
+
+// parseJsonPointer parses RFC 6901 JSON Pointer. Array indexes are left as
+// keys, as they depend on the document.
+func parseJsonPointer(pointer string) ([]string, error) {
+	if len(pointer) == 0 {
+		return []string{}, nil
+	} else if pointer[0] != '/' {
+		return nil, fmt.Errorf(`json pointer "%s" should start with "/"`, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		t = strings.Replace(t, "~1", "/", -1)
+		tokens[i] = strings.Replace(t, "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+func pointerNode(doc interface{}, token string, forAdd bool) (jsonPathNode, error) {
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		return jsonPathNode{key: token, isKey: true}, nil
+	case []interface{}:
+		if token == "-" && forAdd {
+			return jsonPathNode{index: len(doc)}, nil
+		}
+
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 {
+			return jsonPathNode{}, fmt.Errorf(`invalid array index "%s"`, token)
+		}
+
+		return jsonPathNode{index: i}, nil
+	default:
+		return jsonPathNode{}, fmt.Errorf(`"%s" is not in an object or array`, token)
+	}
+}
+
+func pointerNodes(doc interface{}, tokens []string, forAdd bool) ([]jsonPathNode, error) {
+	nodes := make([]jsonPathNode, 0, len(tokens))
+	for i, t := range tokens {
+		n, err := pointerNode(doc, t, forAdd && i+1 == len(tokens))
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+		if i+1 < len(tokens) {
+			doc, err = jsonGet(doc, []jsonPathNode{n})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nodes, nil
+}
+
+func jsonPathString(nodes []jsonPathNode) string {
+	s := "$"
+	for _, n := range nodes {
+		s += n.String()
+	}
+
+	return s
+}
+
+func jsonGet(doc interface{}, nodes []jsonPathNode) (interface{}, error) {
+	for i, n := range nodes {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[n.key]
+			if !n.isKey || !ok {
+				return nil, fmt.Errorf("missing path %s", jsonPathString(nodes[:i+1]))
+			}
+
+			doc = v
+		case []interface{}:
+			if n.isKey || n.index >= len(d) {
+				return nil, fmt.Errorf("missing path %s", jsonPathString(nodes[:i+1]))
+			}
+
+			doc = d[n.index]
+		default:
+			return nil, fmt.Errorf("missing path %s", jsonPathString(nodes[:i+1]))
+		}
+	}
+
+	return doc, nil
+}
+
+// jsonEdit calls edit with the parent of nodes, then replaces the parent
+// by the result, and returns the new document.
+func jsonEdit(doc interface{}, nodes []jsonPathNode, edit func(parent interface{}, last jsonPathNode) (interface{}, error)) (interface{}, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("can't edit the root")
+	}
+
+	parentPath := nodes[:len(nodes)-1]
+	parent, err := jsonGet(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := edit(parent, nodes[len(nodes)-1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", jsonPathString(nodes), err)
+	}
+
+	if len(parentPath) == 0 {
+		return p, nil
+	}
+
+	// array may be reallocated, so set it back
+	return jsonEdit(doc, parentPath, func(grand interface{}, last jsonPathNode) (interface{}, error) {
+		return jsonPut(grand, last, p, false)
+	})
+}
+
+func jsonPut(parent interface{}, n jsonPathNode, value interface{}, insert bool) (interface{}, error) {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if !n.isKey {
+			return nil, fmt.Errorf("not an array")
+		}
+
+		p[n.key] = value
+		return p, nil
+	case []interface{}:
+		if n.isKey {
+			return nil, fmt.Errorf("not an object")
+		}
+
+		if insert {
+			if n.index > len(p) {
+				return nil, fmt.Errorf("index out of range")
+			}
+
+			p = append(p, nil)
+			copy(p[n.index+1:], p[n.index:])
+			p[n.index] = value
+		} else {
+			if n.index >= len(p) {
+				return nil, fmt.Errorf("index out of range")
+			}
+
+			p[n.index] = value
+		}
+
+		return p, nil
+	default:
+		return nil, fmt.Errorf("not an object or array")
+	}
+}
+
+func jsonRemove(parent interface{}, n jsonPathNode) (interface{}, error) {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[n.key]; !n.isKey || !ok {
+			return nil, fmt.Errorf("missing")
+		}
+
+		delete(p, n.key)
+		return p, nil
+	case []interface{}:
+		if n.isKey || n.index >= len(p) {
+			return nil, fmt.Errorf("missing")
+		}
+
+		return append(p[:n.index], p[n.index+1:]...), nil
+	default:
+		return nil, fmt.Errorf("not an object or array")
+	}
+}
+
+func jsonSet(doc interface{}, nodes []jsonPathNode, value interface{}) (interface{}, error) {
+	if len(nodes) == 0 {
+		return value, nil
+	}
+
+	return jsonEdit(doc, nodes, func(parent interface{}, last jsonPathNode) (interface{}, error) {
+		return jsonPut(parent, last, value, false)
+	})
+}
+
+func jsonDelete(doc interface{}, nodes []jsonPathNode) (interface{}, error) {
+	return jsonEdit(doc, nodes, jsonRemove)
+}
+
+type jsonPatchOp struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+func parseJsonPatch(patch []byte) ([]jsonPatchOp, error) {
+	var ops []jsonPatchOp
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf(`json patch "%s" %s missed value`, op.Op, op.Path)
+			}
+		case "remove":
+		case "move", "copy":
+			if _, err := parseJsonPointer(op.From); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf(`unknown json patch op "%s"`, op.Op)
+		}
+
+		if _, err := parseJsonPointer(op.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	return ops, nil
+}
+
+func (op *jsonPatchOp) value() (interface{}, error) {
+	return decodeJson(*op.Value)
+}
+
+// applyJsonPatch applies RFC 6902 operations one by one, stops at the
+// first failed one, as the standard requires.
+func applyJsonPatch(doc interface{}, ops []jsonPatchOp) (interface{}, error) {
+	for _, op := range ops {
+		var err error
+		doc, err = applyJsonPatchOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf(`json patch %s "%s": %v`, op.Op, op.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func applyJsonPatchOp(doc interface{}, op jsonPatchOp) (interface{}, error) {
+	tokens, _ := parseJsonPointer(op.Path)
+	nodes, err := pointerNodes(doc, tokens, op.Op == "add" || op.Op == "move" || op.Op == "copy")
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(doc, value interface{}) (interface{}, error) {
+		if len(nodes) == 0 {
+			return value, nil
+		}
+
+		return jsonEdit(doc, nodes, func(parent interface{}, last jsonPathNode) (interface{}, error) {
+			return jsonPut(parent, last, value, true)
+		})
+	}
+
+	from := func() ([]jsonPathNode, interface{}, error) {
+		tokens, _ := parseJsonPointer(op.From)
+		nodes, err := pointerNodes(doc, tokens, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		v, err := jsonGet(doc, nodes)
+		return nodes, v, err
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, v)
+	case "remove":
+		return jsonDelete(doc, nodes)
+	case "replace":
+		if _, err := jsonGet(doc, nodes); err != nil {
+			return nil, err
+		}
+
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonSet(doc, nodes, v)
+	case "move":
+		fromNodes, v, err := from()
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err = jsonDelete(doc, fromNodes)
+		if err != nil {
+			return nil, err
+		}
+
+		// the target may change after removing
+		nodes, err = pointerNodes(doc, tokens, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, v)
+	case "copy":
+		_, v, err := from()
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := encodeJson(v)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err = decodeJson(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, v)
+	case "test":
+		v, err := jsonGet(doc, nodes)
+		if err != nil {
+			return nil, err
+		}
+
+		expect, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+
+		a, _ := encodeJson(v)
+		b, _ := encodeJson(expect)
+		if !bytes.Equal(a, b) {
+			return nil, fmt.Errorf("test failed: %s != %s", string(a), string(b))
+		}
+
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown op")
+	}
+}
+
+func decodeJson(content []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+
+	var v interface{}
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.More() {
+		return nil, fmt.Errorf("unexpected content after json value")
+	}
+
+	return v, nil
+}
+
+func encodeJson(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	err := e.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
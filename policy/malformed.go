@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const malformedKeyword = "malformed"
+
+const (
+	MalformedBadStatus      = "bad-status"
+	MalformedLengthOver     = "length-over"
+	MalformedLengthUnder    = "length-under"
+	MalformedDupHeaders     = "dup-headers"
+	MalformedBadChunk       = "bad-chunk"
+	MalformedNoFinalChunk   = "no-final-chunk"
+	MalformedNonAsciiHeader = "non-ascii-header"
+	MalformedHttp09         = "http09"
+)
+
+var malformedKinds = []string{
+	MalformedBadStatus,
+	MalformedLengthOver,
+	MalformedLengthUnder,
+	MalformedDupHeaders,
+	MalformedBadChunk,
+	MalformedNoFinalChunk,
+	MalformedNonAsciiHeader,
+	MalformedHttp09,
+}
+
+var malformedComments = map[string]string{
+	MalformedBadStatus:      "错误的状态行",
+	MalformedLengthOver:     "Content-Length 大于内容",
+	MalformedLengthUnder:    "Content-Length 小于内容",
+	MalformedDupHeaders:     "重复且冲突的 headers",
+	MalformedBadChunk:       "非法的 chunk 长度",
+	MalformedNoFinalChunk:   "缺少结束 chunk",
+	MalformedNonAsciiHeader: "header 含非 ASCII 字节",
+	MalformedHttp09:         "HTTP/0.9 式无状态行与 headers",
+}
+
+// MalformedPolicy returns a broken HTTP response from TCP.
+type MalformedPolicy struct {
+	stringPolicy
+}
+
+func init() {
+	regFactory(newStringPolicyFactory(malformedKeyword, "kind", func(kind string) (Policy, error) {
+		if _, ok := malformedComments[kind]; !ok {
+			return nil, fmt.Errorf("%s unknown kind: %s, should be one of %s", malformedKeyword, kind, strings.Join(malformedKinds, "|"))
+		}
+
+		return &MalformedPolicy{stringPolicy{malformedKeyword, kind, func(kind string) string {
+			return "返回畸形 HTTP 回复：" + malformedComments[kind]
+		}}}, nil
+	}))
+}
+
+func (p *MalformedPolicy) Kind() string {
+	return p.str
+}
+
+// Response makes the whole raw response with body.
+func (p *MalformedPolicy) Response(body []byte) []byte {
+	const ct = "Content-Type: text/plain\r\n"
+	length := func(n int) string {
+		return "Content-Length: " + strconv.Itoa(n) + "\r\n"
+	}
+
+	chunk := func(b []byte) string {
+		return strconv.FormatInt(int64(len(b)), 16) + "\r\n" + string(b) + "\r\n"
+	}
+
+	var head string
+	var rest string
+	switch p.str {
+	case MalformedBadStatus:
+		head = "HTTP/1.1 2OO Okay?\r\n" + ct + length(len(body))
+		rest = string(body)
+	case MalformedLengthOver:
+		head = "HTTP/1.1 200 OK\r\n" + ct + length(len(body)+1024)
+		rest = string(body)
+	case MalformedLengthUnder:
+		head = "HTTP/1.1 200 OK\r\n" + ct + length(len(body)/2)
+		rest = string(body)
+	case MalformedDupHeaders:
+		head = "HTTP/1.1 200 OK\r\n" + ct + length(len(body)) + "Content-Type: application/json\r\n" + length(len(body)+10)
+		rest = string(body)
+	case MalformedBadChunk:
+		head = "HTTP/1.1 200 OK\r\n" + ct + "Transfer-Encoding: chunked\r\n"
+		rest = "zz\r\n" + string(body) + "\r\n0\r\n\r\n"
+	case MalformedNoFinalChunk:
+		head = "HTTP/1.1 200 OK\r\n" + ct + "Transfer-Encoding: chunked\r\n"
+		rest = chunk(body)
+	case MalformedNonAsciiHeader:
+		head = "HTTP/1.1 200 OK\r\n" + ct + length(len(body)) + "X-Asuran-\xff\xfe: \xe4\xb8\xad\x80\x00\r\n"
+		rest = string(body)
+	case MalformedHttp09:
+		return body
+	}
+
+	return []byte(head + "\r\n" + rest)
+}
@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestMalformedPolicy(t *testing.T) {
+	body := []byte("hello, malformed")
+	read := func(kind string) ([]byte, error) {
+		cmd := "malformed " + kind
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Fatalf(`Factory("%s") failed: %v`, cmd, err)
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+		}
+
+		raw := p.(*MalformedPolicy).Response(body)
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		defer resp.Body.Close()
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	for _, kind := range []string{MalformedBadStatus, MalformedLengthOver, MalformedDupHeaders, MalformedBadChunk, MalformedNoFinalChunk, MalformedHttp09} {
+		if _, err := read(kind); err == nil {
+			t.Errorf("malformed %s read without error", kind)
+		}
+	}
+
+	if b, err := read(MalformedLengthUnder); err != nil || len(b) != len(body)/2 {
+		t.Errorf("malformed %s read %q, err: %v", MalformedLengthUnder, b, err)
+	}
+
+	if _, err := Factory("malformed unknown"); err == nil {
+		t.Errorf(`Factory("malformed unknown") should fail`)
+	}
+}
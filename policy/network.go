@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const networkKeyword = "network"
+
+const (
+	networkOff    = "off"
+	networkCustom = "custom"
+)
+
+type NetworkPolicy struct {
+	preset string
+	rtt    float32 // seconds
+	jitter float32 // seconds
+	down   float32 // B/s
+	up     float32 // B/s
+	reset  float32 // [0, 1]
+}
+
+var networkPresets = map[string]NetworkPolicy{
+	"2g":         {"2g", 0.8, 0.2, 32 * 1024, 16 * 1024, 0},
+	"3g":         {"3g", 0.3, 0.1, 200 * 1024, 94 * 1024, 0},
+	"lte":        {"lte", 0.07, 0.02, 1.5 * 1024 * 1024, 640 * 1024, 0},
+	"lossy-wifi": {"lossy-wifi", 0.04, 0.15, 1024 * 1024, 512 * 1024, 0.05},
+}
+
+type networkPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(networkPolicyFactory))
+}
+
+func (*networkPolicyFactory) Keyword() string {
+	return networkKeyword
+}
+
+func (*networkPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf(`%s need (2g|3g|lte|lossy-wifi|off|<pack-name>|custom ...)`, networkKeyword)
+	}
+
+	preset := args[0]
+	if p, ok := networkPresets[preset]; ok {
+		return &p, args[1:], nil
+	} else if preset != networkCustom {
+		if strings.IndexAny(preset, "/:.") >= 0 {
+			return nil, args, fmt.Errorf(`%s unknown preset or pack name: %s`, networkKeyword, preset)
+		}
+
+		return &NetworkPolicy{preset: preset}, args[1:], nil
+	}
+
+	p := &NetworkPolicy{preset: networkCustom}
+	rest := args[1:]
+	for len(rest) >= 2 {
+		var err error
+		switch rest[0] {
+		case "rtt":
+			p.rtt, err = parseDuration(rest[1])
+		case "jitter":
+			p.jitter, err = parseDuration(rest[1])
+		case "down":
+			p.down, err = parseSpeed(rest[1])
+		case "up":
+			p.up, err = parseSpeed(rest[1])
+		case "reset":
+			p.reset, err = parseRate(rest[1])
+		default:
+			return p, rest, nil
+		}
+
+		if err != nil {
+			return nil, args, fmt.Errorf(`%s custom %s invalid: %v`, networkKeyword, rest[0], err)
+		}
+
+		rest = rest[2:]
+	}
+
+	return p, rest, nil
+}
+
+// parseRate accepts "5%" or "0.05".
+func parseRate(s string) (float32, error) {
+	times := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = s[:len(s)-1]
+		times = 0.01
+	}
+
+	f, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	f *= times
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("rate out of [0, 100%%]: %s", s)
+	}
+
+	return float32(f), nil
+}
+
+func formatRate(rate float32) string {
+	return strconv.FormatFloat(float64(rate)*100, 'f', -1, 32) + "%"
+}
+
+func (p *NetworkPolicy) Keyword() string {
+	return networkKeyword
+}
+
+func (p *NetworkPolicy) Command() string {
+	if p.preset != networkCustom {
+		return networkKeyword + " " + p.preset
+	}
+
+	c := []string{networkKeyword, networkCustom}
+	if p.rtt > 0 {
+		c = append(c, "rtt", formatDuration(p.rtt))
+	}
+
+	if p.jitter > 0 {
+		c = append(c, "jitter", formatDuration(p.jitter))
+	}
+
+	if p.down > 0 {
+		c = append(c, "down", formatSpeed(p.down))
+	}
+
+	if p.up > 0 {
+		c = append(c, "up", formatSpeed(p.up))
+	}
+
+	if p.reset > 0 {
+		c = append(c, "reset", formatRate(p.reset))
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *NetworkPolicy) Comment() string {
+	if p.Off() {
+		return "不模拟网络状况"
+	} else if len(p.Pack()) > 0 {
+		return "模拟网络状况 " + p.preset + "（自定义包）"
+	}
+
+	c := make([]string, 0, 5)
+	if p.rtt > 0 || p.jitter > 0 {
+		c = append(c, "延时 "+formatDuration(p.rtt)+"±"+formatDuration(p.jitter))
+	}
+
+	if p.down > 0 {
+		c = append(c, "下行 "+formatSpeed(p.down))
+	}
+
+	if p.up > 0 {
+		c = append(c, "上行 "+formatSpeed(p.up))
+	}
+
+	if p.reset > 0 {
+		c = append(c, "断开率 "+formatRate(p.reset))
+	}
+
+	return "模拟网络状况 " + p.preset + "：" + strings.Join(c, "，")
+}
+
+func (p *NetworkPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *NetworkPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *NetworkPolicy) Off() bool {
+	return p.preset == networkOff
+}
+
+// Pack returns the pack name which defines a custom network, or "".
+func (p *NetworkPolicy) Pack() string {
+	if _, ok := networkPresets[p.preset]; ok || p.preset == networkCustom || p.preset == networkOff {
+		return ""
+	}
+
+	return p.preset
+}
+
+func (p *NetworkPolicy) Preset() string {
+	return p.preset
+}
+
+func (p *NetworkPolicy) Latency() bool {
+	return p.rtt > 0 || p.jitter > 0
+}
+
+// RandDuration returns rtt with a jitter of [-jitter, jitter], but not less
+// than 0.
+func (p *NetworkPolicy) RandDuration(r *rand.Rand) time.Duration {
+	t := p.rtt
+	if p.jitter > 0 {
+		t += (r.Float32()*2 - 1) * p.jitter
+	}
+
+	if t < 0 {
+		t = 0
+	}
+
+	return time.Duration(float64(t) * float64(time.Second))
+}
+
+func (p *NetworkPolicy) Down() float32 {
+	return p.down
+}
+
+func (p *NetworkPolicy) Up() float32 {
+	return p.up
+}
+
+func (p *NetworkPolicy) Reset(r *rand.Rand) bool {
+	return p.reset > 0 && r.Float32() < p.reset
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestNetworkPolicy(t *testing.T) {
+	check := func(cmd string) *NetworkPolicy {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return nil
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+		}
+
+		n, ok := p.(*NetworkPolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+			return nil
+		}
+
+		return n
+	}
+
+	if n := check("network 3g"); n != nil {
+		if n.Down() != 200*1024 || n.Up() != 94*1024 || !n.Latency() || len(n.Pack()) != 0 {
+			t.Errorf(`network 3g wrong: %v`, n)
+		}
+
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 100; i++ {
+			d := n.RandDuration(r)
+			if d < 200*time.Millisecond || d > 400*time.Millisecond {
+				t.Errorf(`network 3g RandDuration() out of range: %v`, d)
+			}
+		}
+	}
+
+	if n := check("network off"); n != nil && !n.Off() {
+		t.Errorf(`network off should be Off()`)
+	}
+
+	if n := check("network subway"); n != nil && n.Pack() != "subway" {
+		t.Errorf(`network subway Pack() wrong: %s`, n.Pack())
+	}
+
+	if n := check("network custom rtt 500ms jitter 100ms down 50KB/s up 20KB/s reset 10%"); n != nil {
+		if n.RandDuration(rand.New(rand.NewSource(1))) < 400*time.Millisecond || n.Down() != 50*1024 || n.Up() != 20*1024 {
+			t.Errorf(`network custom wrong: %v`, n)
+		}
+	}
+
+	cmd := "url network custom down 1KB/s g.cn"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Errorf(`FactoryUrl("%s") failed: %v`, cmd, err)
+	} else if u.Command() != cmd || u.Network() == nil || u.Target() != "g.cn" {
+		t.Errorf(`FactoryUrl("%s") wrong: %s`, cmd, u.Command())
+	}
+
+	for _, cmd := range []string{"network", "network custom reset 200%", "network custom rtt x", "url network g.cn"} {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const offlineKeyword = "offline"
+
+const (
+	OfflineExact       = "exact"
+	OfflineIgnoreQuery = "ignore-query"
+	OfflineFuzzy       = "fuzzy"
+)
+
+const defaultOfflineStatus = 504
+
+// OfflinePolicy answers all requests of a device from recorded traffic,
+// never from the upstream.
+type OfflinePolicy struct {
+	on     bool
+	match  string
+	status int
+}
+
+type offlinePolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(offlinePolicyFactory))
+}
+
+func (*offlinePolicyFactory) Keyword() string {
+	return offlineKeyword
+}
+
+func (*offlinePolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf(`%s need (on|off)`, offlineKeyword)
+	}
+
+	switch args[0] {
+	case "off":
+		return &OfflinePolicy{}, args[1:], nil
+	case "on":
+	default:
+		return nil, args, fmt.Errorf(`%s need (on|off): %s`, offlineKeyword, args[0])
+	}
+
+	p := &OfflinePolicy{true, OfflineExact, defaultOfflineStatus}
+	rest := args[1:]
+	if len(rest) > 0 {
+		switch rest[0] {
+		case OfflineExact, OfflineIgnoreQuery, OfflineFuzzy:
+			p.match = rest[0]
+			rest = rest[1:]
+		}
+	}
+
+	if len(rest) > 0 && rest[0] == "status" {
+		if len(rest) < 2 {
+			return nil, args, fmt.Errorf(`%s status need a status code`, offlineKeyword)
+		}
+
+		status, err := strconv.Atoi(rest[1])
+		if err != nil || status < 100 || status > 999 {
+			return nil, args, fmt.Errorf(`%s invalid status: %s`, offlineKeyword, rest[1])
+		}
+
+		p.status = status
+		rest = rest[2:]
+	}
+
+	if len(rest) > 0 {
+		return nil, args, fmt.Errorf(`%s unknown arg: %s`, offlineKeyword, rest[0])
+	}
+
+	return p, rest, nil
+}
+
+func (p *OfflinePolicy) Keyword() string {
+	return offlineKeyword
+}
+
+func (p *OfflinePolicy) Command() string {
+	if p.Off() {
+		return offlineKeyword + " off"
+	}
+
+	c := []string{offlineKeyword, "on", p.match}
+	if p.status != defaultOfflineStatus {
+		c = append(c, "status", strconv.Itoa(p.status))
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *OfflinePolicy) Comment() string {
+	if p.Off() {
+		return "在线，请求源站"
+	}
+
+	c := "离线，从历史记录回复"
+	switch p.match {
+	case OfflineIgnoreQuery:
+		c += "，忽略 URL 参数"
+	case OfflineFuzzy:
+		c += "，模糊匹配 URL"
+	}
+
+	return c + "，找不到时回复 " + strconv.Itoa(p.status)
+}
+
+func (p *OfflinePolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *OfflinePolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *OfflinePolicy) Off() bool {
+	return !p.on
+}
+
+// Match is one of OfflineExact, OfflineIgnoreQuery and OfflineFuzzy.
+func (p *OfflinePolicy) Match() string {
+	return p.match
+}
+
+// Status is for requests without any record.
+func (p *OfflinePolicy) Status() int {
+	return p.status
+}
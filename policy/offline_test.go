@@ -0,0 +1,43 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestOfflinePolicy(t *testing.T) {
+	check := func(cmd, command, match string, status int) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != command {
+			t.Errorf(`Factory("%s").Command() wrong: %s`, cmd, p.Command())
+		}
+
+		o, ok := p.(*OfflinePolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+		} else if o.Off() != (len(match) == 0) || o.Match() != match || (!o.Off() && o.Status() != status) {
+			t.Errorf(`Factory("%s") match/status: %s/%d vs %s/%d`, cmd, o.Match(), o.Status(), match, status)
+		}
+	}
+
+	check("offline off", "offline off", "", 0)
+	check("offline on", "offline on exact", OfflineExact, 504)
+	check("offline on ignore-query", "offline on ignore-query", OfflineIgnoreQuery, 504)
+	check("offline on fuzzy status 404", "offline on fuzzy status 404", OfflineFuzzy, 404)
+	check("offline on status 503", "offline on exact status 503", OfflineExact, 503)
+
+	bad := func(cmd string) {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+
+	bad("offline")
+	bad("offline yes")
+	bad("offline on loose")
+	bad("offline on status")
+	bad("offline on status x")
+	bad("offline on exact status 42")
+}
@@ -115,3 +115,7 @@ func (s *opStringPolicy) Op(op string) bool {
 
 	return false
 }
+
+func (s *opStringPolicy) Value() string {
+	return s.str
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const recordKeyword = "record"
+
+var sessionNameRegexp = regexp.MustCompile(`^[0-9A-Za-z_.\-]+$`)
+
+func checkSessionName(keyword, name string) error {
+	if !sessionNameRegexp.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf(`%s invalid session name: %s, should be of [0-9A-Za-z_.-]`, keyword, name)
+	}
+
+	return nil
+}
+
+// RecordPolicy saves every proxied exchange of a device to a session.
+type RecordPolicy struct {
+	session string // empty for off
+}
+
+type recordPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(recordPolicyFactory))
+}
+
+func (*recordPolicyFactory) Keyword() string {
+	return recordKeyword
+}
+
+func (*recordPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) > 0 && args[0] == "off" {
+		return &RecordPolicy{}, args[1:], nil
+	} else if len(args) < 2 || args[0] != "on" {
+		return nil, args, fmt.Errorf(`%s need (on <session>|off)`, recordKeyword)
+	} else if err := checkSessionName(recordKeyword, args[1]); err != nil {
+		return nil, args, err
+	}
+
+	return &RecordPolicy{args[1]}, args[2:], nil
+}
+
+func (p *RecordPolicy) Keyword() string {
+	return recordKeyword
+}
+
+func (p *RecordPolicy) Command() string {
+	if p.Off() {
+		return recordKeyword + " off"
+	}
+
+	return recordKeyword + " on " + p.session
+}
+
+func (p *RecordPolicy) Comment() string {
+	if p.Off() {
+		return "不录制"
+	}
+
+	return "录制所有代理请求到会话 " + p.session
+}
+
+func (p *RecordPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *RecordPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *RecordPolicy) Off() bool {
+	return len(p.session) == 0
+}
+
+func (p *RecordPolicy) Session() string {
+	return p.session
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestRecordPolicy(t *testing.T) {
+	check := func(cmd, session string) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+		}
+
+		r, ok := p.(*RecordPolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+		} else if r.Session() != session || r.Off() != (len(session) == 0) {
+			t.Errorf(`Factory("%s") session: %s vs %s`, cmd, r.Session(), session)
+		}
+	}
+
+	check("record off", "")
+	check("record on login-1.2_a", "login-1.2_a")
+
+	for _, cmd := range []string{"record", "record on", "record on ..", "record on a/b", "record start x"} {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
+
+func TestReplayPolicy(t *testing.T) {
+	check := func(cmd, command, session string, strict, ordered, body bool) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != command {
+			t.Errorf(`Factory("%s").Command() wrong: %s`, cmd, p.Command())
+		}
+
+		r, ok := p.(*ReplayPolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+		} else if r.Session() != session || r.Strict() != strict || r.Ordered() != ordered || r.Body() != body {
+			t.Errorf(`Factory("%s") wrong: %v`, cmd, r)
+		}
+	}
+
+	check("replay off", "replay off", "", false, false, false)
+	check("replay on s1", "replay on s1", "s1", false, false, false)
+	check("replay on s1 body strict", "replay on s1 strict body", "s1", true, false, true)
+	check("replay on s1 ordered strict body", "replay on s1 strict ordered body", "s1", true, true, true)
+
+	for _, cmd := range []string{"replay", "replay on", "replay on s1 loose", "replay on a%20b"} {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
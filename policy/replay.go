@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+const replayKeyword = "replay"
+
+// ReplayPolicy answers requests of a device from a recorded session,
+// matched by method and url, and the body hash with body.
+type ReplayPolicy struct {
+	session string // empty for off
+	strict  bool
+	ordered bool
+	body    bool
+}
+
+type replayPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(replayPolicyFactory))
+}
+
+func (*replayPolicyFactory) Keyword() string {
+	return replayKeyword
+}
+
+func (*replayPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) > 0 && args[0] == "off" {
+		return &ReplayPolicy{}, args[1:], nil
+	} else if len(args) < 2 || args[0] != "on" {
+		return nil, args, fmt.Errorf(`%s need (on <session> [strict] [ordered] [body]|off)`, replayKeyword)
+	} else if err := checkSessionName(replayKeyword, args[1]); err != nil {
+		return nil, args, err
+	}
+
+	p := &ReplayPolicy{session: args[1]}
+	rest := args[2:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case "strict":
+			p.strict = true
+		case "ordered":
+			p.ordered = true
+		case "body":
+			p.body = true
+		default:
+			return nil, args, fmt.Errorf(`%s unknown arg: %s`, replayKeyword, rest[0])
+		}
+
+		rest = rest[1:]
+	}
+
+	return p, rest, nil
+}
+
+func (p *ReplayPolicy) Keyword() string {
+	return replayKeyword
+}
+
+func (p *ReplayPolicy) Command() string {
+	if p.Off() {
+		return replayKeyword + " off"
+	}
+
+	c := []string{replayKeyword, "on", p.session}
+	if p.strict {
+		c = append(c, "strict")
+	}
+
+	if p.ordered {
+		c = append(c, "ordered")
+	}
+
+	if p.body {
+		c = append(c, "body")
+	}
+
+	return strings.Join(c, " ")
+}
+
+func (p *ReplayPolicy) Comment() string {
+	if p.Off() {
+		return "不回放"
+	}
+
+	c := "从会话 " + p.session + " 回放"
+	if p.body {
+		c += "，按请求内容区分"
+	}
+
+	if p.ordered {
+		c += "，相同请求按录制顺序回复"
+	}
+
+	if p.strict {
+		c += "，找不到时报错"
+	}
+
+	return c
+}
+
+func (p *ReplayPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *ReplayPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (p *ReplayPolicy) Off() bool {
+	return len(p.session) == 0
+}
+
+func (p *ReplayPolicy) Session() string {
+	return p.session
+}
+
+// Strict fails requests unmatched, which are proxied otherwise.
+func (p *ReplayPolicy) Strict() bool {
+	return p.strict
+}
+
+// Ordered answers repeated requests by responses in recorded order.
+func (p *ReplayPolicy) Ordered() bool {
+	return p.ordered
+}
+
+// Body tells requests apart by the hash of body.
+func (p *ReplayPolicy) Body() bool {
+	return p.body
+}
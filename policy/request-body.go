@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"github.com/benbearchen/asuran/util/cmd"
+
+	"fmt"
+	"net/url"
+)
+
+const requestBodyKeyword = "request-body"
+
+const (
+	requestBodyRewrite   = "rewrite"
+	requestBodyReplace   = "replace"
+	requestBodyJsonPatch = "json-patch"
+)
+
+type RequestBodyPolicy struct {
+	op       string
+	value    string
+	content  []byte
+	replacer *Replacer
+	patch    *JsonPatchPolicy
+}
+
+type requestBodyPolicyFactory struct {
+}
+
+func init() {
+	regFactory(new(requestBodyPolicyFactory))
+}
+
+func (*requestBodyPolicyFactory) Keyword() string {
+	return requestBodyKeyword
+}
+
+func (*requestBodyPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) < 2 {
+		return nil, args, fmt.Errorf(`%s need (%s <url-encoded-content>|%s /<regex>/<replacement>/[g]|%s <url-encoded-json-patch>)`, requestBodyKeyword, requestBodyRewrite, requestBodyReplace, requestBodyJsonPatch)
+	}
+
+	p := &RequestBodyPolicy{op: args[0], value: args[1]}
+	switch p.op {
+	case requestBodyRewrite:
+		c, err := decodeContent(p.value)
+		if err != nil {
+			return nil, args, err
+		}
+
+		p.content = c
+	case requestBodyReplace:
+		replacer, err := NewFlagReplacer(p.value)
+		if err != nil {
+			return nil, args, err
+		}
+
+		p.replacer = replacer
+	case requestBodyJsonPatch:
+		patch, err := newJsonPatchPolicy(p.value)
+		if err != nil {
+			return nil, args, err
+		}
+
+		v, _ := url.QueryUnescape(p.value)
+		p.value = url.QueryEscape(v)
+		p.patch = patch
+	default:
+		return nil, args, fmt.Errorf(`%s unknown op: %s`, requestBodyKeyword, p.op)
+	}
+
+	return p, args[2:], nil
+}
+
+func (p *RequestBodyPolicy) Keyword() string {
+	return requestBodyKeyword
+}
+
+func (p *RequestBodyPolicy) Command() string {
+	switch p.op {
+	case requestBodyRewrite:
+		return requestBodyKeyword + " " + p.op + " " + url.QueryEscape(string(p.content))
+	case requestBodyReplace:
+		return requestBodyKeyword + " " + p.op + " " + cmd.Quote(p.value)
+	default:
+		return requestBodyKeyword + " " + p.op + " " + p.value
+	}
+}
+
+func (p *RequestBodyPolicy) Comment() string {
+	switch p.op {
+	case requestBodyRewrite:
+		return "以特定内容替换请求内容"
+	case requestBodyReplace:
+		return "替换请求内容 " + p.value
+	case requestBodyJsonPatch:
+		return "以 JSON Patch 修改请求内容"
+	default:
+		return ""
+	}
+}
+
+func (p *RequestBodyPolicy) Update(n Policy) error {
+	switch n := n.(type) {
+	case *RequestBodyPolicy:
+		*p = *n
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+// Edit returns the new body, and errors for the original would be sent.
+func (p *RequestBodyPolicy) Edit(body []byte) ([]byte, []error) {
+	switch p.op {
+	case requestBodyRewrite:
+		return p.content, nil
+	case requestBodyReplace:
+		return p.replacer.ReplaceBytes(body), nil
+	case requestBodyJsonPatch:
+		return EditJsonContent(body, []JsonEditor{p.patch})
+	default:
+		return body, nil
+	}
+}
+
+// Whole means the original body is unnecessary.
+func (p *RequestBodyPolicy) Whole() bool {
+	return p.op == requestBodyRewrite
+}
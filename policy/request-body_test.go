@@ -0,0 +1,55 @@
+package policy
+
+import "testing"
+
+func TestRequestBodyPolicy(t *testing.T) {
+	check := func(cmd, body, target string) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		} else if p.Command() != cmd {
+			t.Errorf(`Factory("%s").Command() changed: %s`, cmd, p.Command())
+		}
+
+		rb, ok := p.(*RequestBodyPolicy)
+		if !ok {
+			t.Errorf(`Factory("%s") invalid class`, cmd)
+			return
+		}
+
+		c, errs := rb.Edit([]byte(body))
+		if string(c) != target || len(errs) != 0 {
+			t.Errorf(`Factory("%s").Edit(%s) => %s != %s, errs: %v`, cmd, body, string(c), target, errs)
+		}
+	}
+
+	check("request-body rewrite a%3D1", "a=2", "a=1")
+	check("request-body replace /sign=[^&]*/sign=bad/", "a=1&sign=xyz", "a=1&sign=bad")
+	check("request-body json-patch %5B%7B%22op%22%3A%22replace%22%2C%22path%22%3A%22%2Fa%22%2C%22value%22%3A2%7D%5D", `{"a":1}`, `{"a":2}`)
+
+	check("request-body rewrite a%3D1+2", "a=2", "a=1 2")
+	check("request-body replace `/a b/c d/g`", "a b&a b", "c d&c d")
+	check("request-body replace /100%/x/", "a=100%", "a=x")
+	check(`request-body replace /\d+/n/g`, "a=12&b=3", "a=n&b=n")
+	check("request-body json-patch %5B%7B%22op%22%3A%22add%22%2C%22path%22%3A%22%2Fb%22%2C%22value%22%3A%22x+y%22%7D%5D", `{"a":1}`, `{"a":1,"b":"x y"}`)
+
+	roundTrip := func(cmd, body, target string) {
+		p, err := Factory(cmd)
+		if err != nil {
+			t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+			return
+		}
+
+		check(p.Command(), body, target)
+	}
+
+	roundTrip("request-body rewrite `a=1 2`", "a=2", "a=1 2")
+	roundTrip("request-body replace `/a b/c d/g`", "a b&a b", "c d&c d")
+
+	for _, cmd := range []string{"request-body", "request-body rewrite", "request-body x y", "request-body replace /a/b", "request-body json-patch %7B%7D"} {
+		if _, err := Factory(cmd); err == nil {
+			t.Errorf(`Factory("%s") should fail`, cmd)
+		}
+	}
+}
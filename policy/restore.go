@@ -3,13 +3,28 @@ package policy
 const restoreKeyword = "restore"
 
 type RestorePolicy struct {
-	stringPolicy
+	opStringPolicy
+	contentTemplate
 }
 
 func init() {
-	regFactory(newStringPolicyFactory(restoreKeyword, "stored id", func(id string) (Policy, error) {
-		return &RestorePolicy{stringPolicy{restoreKeyword, id, func(id string) string {
-			return "以预定义 " + id + " 内容返回"
-		}}}, nil
+	regFactory(newOpStringPolicyFactory(restoreKeyword, []string{opTemplate}, "stored id", func(ops []string, id string) (Policy, error) {
+		return &RestorePolicy{opStringPolicy{restoreKeyword, ops, id, func(ops []string, id string) string {
+			if len(ops) > 0 {
+				return "以预定义 " + id + " 内容模板返回"
+			} else {
+				return "以预定义 " + id + " 内容返回"
+			}
+		}}, contentTemplate{}}, nil
 	}))
 }
+
+func (r *RestorePolicy) Template() bool {
+	return r.Op(opTemplate)
+}
+
+// Execute renders the stored content, which is parsed on each call,
+// as the store may be changed at any time.
+func (r *RestorePolicy) Execute(content []byte, data *TemplateData) ([]byte, error) {
+	return r.execute(content, data)
+}
@@ -3,22 +3,47 @@ package policy
 const rewriteKeyword = "rewrite"
 
 type RewritePolicy struct {
-	stringPolicy
+	opStringPolicy
+	contentTemplate
 }
 
 func init() {
-	regFactory(newStringPolicyFactory(rewriteKeyword, "url encoded content", func(content string) (Policy, error) {
+	regFactory(newOpStringPolicyFactory(rewriteKeyword, []string{opTemplate}, "url encoded content", func(ops []string, content string) (Policy, error) {
 		err := checkEncodedContent(content)
 		if err != nil {
 			return nil, err
-		} else {
-			return &RewritePolicy{stringPolicy{rewriteKeyword, content, func(string) string {
-				return "以特定内容返回"
-			}}}, nil
 		}
+
+		if len(ops) > 0 {
+			err = checkEncodedTemplate(content)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &RewritePolicy{opStringPolicy{rewriteKeyword, ops, content, func(ops []string, content string) string {
+			if len(ops) > 0 {
+				return "以特定内容模板返回"
+			} else {
+				return "以特定内容返回"
+			}
+		}}, contentTemplate{}}, nil
 	}))
 }
 
 func (r *RewritePolicy) Content() ([]byte, error) {
 	return decodeContent(r.str)
 }
+
+func (r *RewritePolicy) Template() bool {
+	return r.Op(opTemplate)
+}
+
+func (r *RewritePolicy) Execute(data *TemplateData) ([]byte, error) {
+	c, err := r.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.execute(c, data)
+}
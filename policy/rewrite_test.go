@@ -30,3 +30,36 @@ func TestRewritePolicy(t *testing.T) {
 	}
 
 }
+
+func TestRewriteTemplatePolicy(t *testing.T) {
+	cmd := "rewrite template id%3D%7B%7Bcapture%20.Captures%200%7D%7D%26n%3D%7B%7B.Counter%7D%7D"
+	p, err := Factory(cmd)
+	if err != nil {
+		t.Errorf(`Factory("%s") failed: %v`, cmd, err)
+		return
+	} else if p.Command() != cmd {
+		t.Errorf(`Factory("%s").Command() failed: "%s"`, cmd, p.Command())
+	}
+
+	r, ok := p.(*RewritePolicy)
+	if !ok {
+		t.Errorf(`Factory("%s") invalid class`, cmd)
+		return
+	} else if !r.Template() {
+		t.Errorf(`Factory("%s").Template() should be true`, cmd)
+	}
+
+	for i, e := range []string{"id=12&n=1", "id=12&n=2"} {
+		c, err := r.Execute(&TemplateData{Captures: []string{"12"}})
+		if err != nil {
+			t.Errorf(`Factory("%s").Execute() #%d failed: %v`, cmd, i, err)
+		} else if string(c) != e {
+			t.Errorf(`Factory("%s").Execute() #%d: "%s" != "%s"`, cmd, i, string(c), e)
+		}
+	}
+
+	cmd = "rewrite template %7B%7B.Bad"
+	if _, err := Factory(cmd); err == nil {
+		t.Errorf(`Factory("%s") should fail for invalid template`, cmd)
+	}
+}
@@ -3,16 +3,16 @@ package policy
 import (
 	"fmt"
 	"regexp"
-	"strings"
 )
 
 type Replacer struct {
 	search  *regexp.Regexp
 	replace string
+	global  bool
 }
 
 func NewReplacer(pattern string) (*Replacer, error) {
-	search, replace, err := splitSearchReplace(pattern)
+	search, replace, _, err := splitSearchReplace(pattern)
 	if err != nil {
 		return nil, err
 	}
@@ -22,18 +22,98 @@ func NewReplacer(pattern string) (*Replacer, error) {
 		return nil, err
 	}
 
-	return &Replacer{s, replace}, nil
+	return &Replacer{s, replace, true}, nil
+}
+
+// NewFlagReplacer accepts "/<search>/<replace>/[g]", and replaces only
+// the first match without flag `g'. "/" in search or replace is escaped
+// as "\/", or else another delimiter is used, like "|<search>|<replace>|".
+func NewFlagReplacer(pattern string) (*Replacer, error) {
+	search, replace, flags, err := splitSearchReplace(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	global := false
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			global = true
+		default:
+			return nil, fmt.Errorf(`replace pattern "%s" has unknown flag: %c`, pattern, f)
+		}
+	}
+
+	s, err := regexp.Compile(search)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replacer{s, replace, global}, nil
 }
 
 func (r *Replacer) Replace(source string) string {
-	return r.search.ReplaceAllString(source, r.replace)
+	return string(r.ReplaceBytes([]byte(source)))
+}
+
+func (r *Replacer) ReplaceBytes(source []byte) []byte {
+	if r.global {
+		return r.search.ReplaceAll(source, []byte(r.replace))
+	}
+
+	m := r.search.FindSubmatchIndex(source)
+	if m == nil {
+		return source
+	}
+
+	b := make([]byte, 0, len(source)+len(r.replace))
+	b = append(b, source[:m[0]]...)
+	b = r.search.Expand(b, []byte(r.replace), source, m)
+	b = append(b, source[m[1]:]...)
+	return b
+}
+
+// splitSearchReplace splits pattern by its first char as the delimiter,
+// which can be escaped by "\" in search and replace.
+func splitSearchReplace(pattern string) (string, string, string, error) {
+	if len(pattern) == 0 || !isDelimiter(pattern[0]) {
+		return "", "", "", fmt.Errorf(`replace pattern "%s" should like "/.../.../"`, pattern)
+	}
+
+	d := pattern[0]
+	fields := make([]string, 0, 3)
+	field := make([]byte, 0, len(pattern))
+	for i := 1; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '\\' && i+1 < len(pattern) && pattern[i+1] == d {
+			i++
+			if len(fields) == 0 && regexp.QuoteMeta(string(d)) != string(d) {
+				field = append(field, '\\')
+			}
+
+			field = append(field, d)
+		} else if c == d {
+			fields = append(fields, string(field))
+			field = field[:0]
+		} else {
+			field = append(field, c)
+		}
+	}
+
+	if len(fields) != 2 {
+		return "", "", "", fmt.Errorf(`replace pattern "%s" should like "%c...%c...%c"`, pattern, d, d, d)
+	}
+
+	return fields[0], fields[1], string(field), nil
 }
 
-func splitSearchReplace(pattern string) (search string, replace string, err error) {
-	ss := strings.Split(pattern, "/")
-	if len(ss) < 4 {
-		return "", "", fmt.Errorf(`replace pattern "%s" should like "/.../.../"`, pattern)
+func isDelimiter(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return false
+	case c == '\\', c == ' ', c == '\t', c == '\n', c >= 0x80:
+		return false
 	}
 
-	return ss[1], ss[2], nil
+	return true
 }
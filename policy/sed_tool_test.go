@@ -50,3 +50,34 @@ func TestToolReplacer(t *testing.T) {
 		check(r, pattern, "ab", "xaby")
 	}
 }
+
+func TestToolFlagReplacer(t *testing.T) {
+	check := func(pattern, source, target string) {
+		r, err := NewFlagReplacer(pattern)
+		if err != nil {
+			t.Errorf(`NewFlagReplacer("%s") err: %v`, pattern, err)
+			return
+		}
+
+		result := string(r.ReplaceBytes([]byte(source)))
+		if result != target {
+			t.Errorf(`NewFlagReplacer("%s").ReplaceBytes(%s)=> %s != %s`, pattern, source, result, target)
+		}
+	}
+
+	check("/ab/cd/", "", "")
+	check("/ab/cd/", "abab", "cdab")
+	check("/ab/cd/g", "abab", "cdcd")
+	check("/(a)(b)/${2}${1}/", "xabab", "xbaab")
+	check("/(a)(b)/${2}${1}/g", "xabab", "xbaba")
+	check(`/"debug":\s*false/"debug":true/`, `{"debug": false}`, `{"debug":true}`)
+	check(`/https:\/\/a\.com\//https:\/\/b.com\//g`, `["https://a.com/x","https://a.com/y"]`, `["https://b.com/x","https://b.com/y"]`)
+	check(`|https://a\.com/|https://b.com/|`, `https://a.com/x`, `https://b.com/x`)
+	check(`|a\|b|c\|d|g`, `a|b ab`, `c|d ab`)
+
+	for _, pattern := range []string{"/ab/cd", "/ab/cd/x", "/a/b/c/", "ab/cd/", `/a\/b/`, "|a/b/"} {
+		if _, err := NewFlagReplacer(pattern); err == nil {
+			t.Errorf(`NewFlagReplacer("%s") should fail`, pattern)
+		}
+	}
+}
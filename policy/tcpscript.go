@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const tcpscriptKeyword = "tcpscript"
+
+const opStore = "store"
+
+const (
+	TcpSend      = "send"
+	TcpSleep     = "sleep"
+	TcpHalfClose = "halfclose"
+	TcpReset     = "reset"
+	TcpClose     = "close"
+)
+
+// TcpStep is one step of a tcpscript, Data for send and Duration for sleep.
+type TcpStep struct {
+	Action   string
+	Data     []byte
+	Duration time.Duration
+}
+
+// TcpscriptPolicy writes segments to TCP by steps, inline or from a store.
+type TcpscriptPolicy struct {
+	opStringPolicy
+}
+
+func init() {
+	regFactory(newOpStringPolicyFactory(tcpscriptKeyword, []string{opStore}, "script", func(ops []string, script string) (Policy, error) {
+		if len(ops) == 0 {
+			if _, err := ParseTcpScript(script); err != nil {
+				return nil, err
+			}
+		}
+
+		return &TcpscriptPolicy{opStringPolicy{tcpscriptKeyword, ops, script, func(ops []string, script string) string {
+			if len(ops) > 0 {
+				return "以预定义 " + script + " 脚本分段从 TCP 返回"
+			} else {
+				return "按脚本分段从 TCP 返回"
+			}
+		}}}, nil
+	}))
+}
+
+// Store tells whether Value() is a store id rather than the script.
+func (p *TcpscriptPolicy) Store() bool {
+	return p.Op(opStore)
+}
+
+func (p *TcpscriptPolicy) Steps() ([]TcpStep, error) {
+	return ParseTcpScript(p.str)
+}
+
+// ParseTcpScript parses steps separated by "," or lines, each of which is
+// "send:<url-encoded-content>", "sleep:<duration>", "halfclose", "reset"
+// or "close". Empty steps and lines starting with "#" are ignored.
+func ParseTcpScript(script string) ([]TcpStep, error) {
+	steps := make([]TcpStep, 0)
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, s := range strings.Split(line, ",") {
+			s = strings.TrimSpace(s)
+			if len(s) == 0 {
+				continue
+			}
+
+			if len(steps) > 0 {
+				switch steps[len(steps)-1].Action {
+				case TcpReset, TcpClose:
+					return nil, fmt.Errorf("%s step after %s: %s", tcpscriptKeyword, steps[len(steps)-1].Action, s)
+				}
+			}
+
+			step, err := parseTcpStep(s)
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		}
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%s has no step", tcpscriptKeyword)
+	}
+
+	return steps, nil
+}
+
+func parseTcpStep(s string) (TcpStep, error) {
+	action, arg := s, ""
+	hasArg := false
+	if c := strings.Index(s, ":"); c >= 0 {
+		action, arg = s[:c], s[c+1:]
+		hasArg = true
+	}
+
+	switch action {
+	case TcpSend:
+		data, err := decodeContent(arg)
+		if err != nil {
+			return TcpStep{}, fmt.Errorf("%s invalid content: %s", TcpSend, arg)
+		}
+
+		return TcpStep{Action: action, Data: data}, nil
+	case TcpSleep:
+		d, err := parseDuration(arg)
+		if err != nil || d < 0 {
+			return TcpStep{}, fmt.Errorf("%s invalid duration: %s", TcpSleep, arg)
+		}
+
+		return TcpStep{Action: action, Duration: time.Duration(float64(d) * float64(time.Second))}, nil
+	case TcpHalfClose, TcpReset, TcpClose:
+		if hasArg {
+			return TcpStep{}, fmt.Errorf("%s need no arg: %s", action, s)
+		}
+
+		return TcpStep{Action: action}, nil
+	default:
+		return TcpStep{}, fmt.Errorf("%s unknown step: %s", tcpscriptKeyword, s)
+	}
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTcpscriptPolicy(t *testing.T) {
+	cmd := "url tcpscript send:ab%2C,sleep:1.5s,send:cd,halfclose g.cn/raw"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Fatalf("url(%s) failed: %v", cmd, err)
+	} else if u.Command() != cmd {
+		t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+	}
+
+	p, ok := u.ContentPolicy().(*TcpscriptPolicy)
+	if !ok || p.Store() {
+		t.Fatalf("url(%s).ContentPolicy() wrong: %v", cmd, u.ContentPolicy())
+	}
+
+	steps, err := p.Steps()
+	if err != nil || len(steps) != 4 {
+		t.Fatalf("url(%s) steps wrong: %v, err: %v", cmd, steps, err)
+	} else if steps[0].Action != TcpSend || string(steps[0].Data) != "ab," {
+		t.Errorf("url(%s) step 0 wrong: %v", cmd, steps[0])
+	} else if steps[1].Action != TcpSleep || steps[1].Duration != 1500*time.Millisecond {
+		t.Errorf("url(%s) step 1 wrong: %v", cmd, steps[1])
+	} else if steps[3].Action != TcpHalfClose {
+		t.Errorf("url(%s) step 3 wrong: %v", cmd, steps[3])
+	}
+
+	cmd = "url tcpscript store s1 g.cn/raw"
+	u, err = FactoryUrl(cmd)
+	if err != nil {
+		t.Fatalf("url(%s) failed: %v", cmd, err)
+	} else if p, ok := u.ContentPolicy().(*TcpscriptPolicy); !ok || !p.Store() || p.Value() != "s1" {
+		t.Errorf("url(%s).ContentPolicy() wrong: %v", cmd, u.ContentPolicy())
+	}
+
+	steps, err = ParseTcpScript("# header first\nsend:HTTP%2F1.1%20200%20OK%0D%0A\n\nsleep:2s\nsend:x, reset\n")
+	if err != nil || len(steps) != 4 || steps[3].Action != TcpReset {
+		t.Errorf("ParseTcpScript() wrong: %v, err: %v", steps, err)
+	}
+
+	for _, cmd := range []string{"url tcpscript send:%zz g.cn", "url tcpscript sleep:x g.cn", "url tcpscript close,send:a g.cn", "url tcpscript reset:1 g.cn", "url tcpscript wait:1s g.cn", "url tcpscript , g.cn"} {
+		if _, err := FactoryUrl(cmd); err == nil {
+			t.Errorf("url(%s) should fail", cmd)
+		}
+	}
+}
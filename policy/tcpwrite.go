@@ -3,22 +3,47 @@ package policy
 const tcpwriteKeyword = "tcpwrite"
 
 type TcpwritePolicy struct {
-	stringPolicy
+	opStringPolicy
+	contentTemplate
 }
 
 func init() {
-	regFactory(newStringPolicyFactory(tcpwriteKeyword, "url encoded content", func(content string) (Policy, error) {
+	regFactory(newOpStringPolicyFactory(tcpwriteKeyword, []string{opTemplate}, "url encoded content", func(ops []string, content string) (Policy, error) {
 		err := checkEncodedContent(content)
 		if err != nil {
 			return nil, err
-		} else {
-			return &TcpwritePolicy{stringPolicy{tcpwriteKeyword, content, func(string) string {
-				return "将特定内容从 TCP 返回"
-			}}}, nil
 		}
+
+		if len(ops) > 0 {
+			err = checkEncodedTemplate(content)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &TcpwritePolicy{opStringPolicy{tcpwriteKeyword, ops, content, func(ops []string, content string) string {
+			if len(ops) > 0 {
+				return "将特定内容模板从 TCP 返回"
+			} else {
+				return "将特定内容从 TCP 返回"
+			}
+		}}, contentTemplate{}}, nil
 	}))
 }
 
 func (r *TcpwritePolicy) Content() ([]byte, error) {
 	return decodeContent(r.str)
 }
+
+func (r *TcpwritePolicy) Template() bool {
+	return r.Op(opTemplate)
+}
+
+func (r *TcpwritePolicy) Execute(data *TemplateData) ([]byte, error) {
+	c, err := r.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.execute(c, data)
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"bytes"
+	"net/http"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+const opTemplate = "template"
+
+type TemplateData struct {
+	Method   string
+	URL      string
+	Scheme   string
+	Host     string
+	Path     string
+	RawQuery string
+	Query    map[string][]string
+	Captures []string
+	Header   http.Header
+	Body     string
+	JSON     interface{}
+	Now      time.Time
+	Counter  uint64
+}
+
+type contentTemplate struct {
+	counter uint64
+}
+
+var templateFuncs = template.FuncMap{
+	"capture": func(captures []string, i int) string {
+		if i >= 0 && i < len(captures) {
+			return captures[i]
+		} else {
+			return ""
+		}
+	},
+	"query": func(query map[string][]string, key string) string {
+		if v, ok := query[key]; ok && len(v) > 0 {
+			return v[0]
+		} else {
+			return ""
+		}
+	},
+	"unix": func(t time.Time) int64 {
+		return t.Unix()
+	},
+	"unixms": func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	},
+}
+
+func parseContentTemplate(content string) (*template.Template, error) {
+	return template.New("content").Funcs(templateFuncs).Parse(content)
+}
+
+func (c *contentTemplate) execute(content []byte, data *TemplateData) ([]byte, error) {
+	t, err := parseContentTemplate(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	data.Counter = atomic.AddUint64(&c.counter, 1)
+
+	var b bytes.Buffer
+	err = t.Execute(&b, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	uploadSpeedKeyword = "upload-speed"
+	uploadDelayKeyword = "upload-delay"
+)
+
+// UploadSpeedPolicy throttles reading of the request body from client.
+type UploadSpeedPolicy struct {
+	speed float32
+}
+
+// UploadDelayPolicy waits before reading the request body from client.
+type UploadDelayPolicy struct {
+	duration float32
+}
+
+func init() {
+	regFactory(new(uploadSpeedPolicyFactory))
+	regFactory(new(uploadDelayPolicyFactory))
+}
+
+type uploadSpeedPolicyFactory struct {
+}
+
+func (*uploadSpeedPolicyFactory) Keyword() string {
+	return uploadSpeedKeyword
+}
+
+func (*uploadSpeedPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf("%s need a speed", uploadSpeedKeyword)
+	}
+
+	speed, err := parseSpeed(args[0])
+	if err != nil {
+		return nil, args, err
+	} else if speed <= 0 {
+		return nil, args, fmt.Errorf("%s should be greater than 0: %s", uploadSpeedKeyword, args[0])
+	}
+
+	return &UploadSpeedPolicy{speed}, args[1:], nil
+}
+
+func (s *UploadSpeedPolicy) Keyword() string {
+	return uploadSpeedKeyword
+}
+
+func (s *UploadSpeedPolicy) Command() string {
+	return uploadSpeedKeyword + " " + formatSpeed(s.speed)
+}
+
+func (s *UploadSpeedPolicy) Comment() string {
+	return "上传匀速 " + formatSpeed(s.speed)
+}
+
+func (s *UploadSpeedPolicy) Update(p Policy) error {
+	switch p := p.(type) {
+	case *UploadSpeedPolicy:
+		s.speed = p.speed
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (s *UploadSpeedPolicy) Speed() float32 {
+	return s.speed
+}
+
+type uploadDelayPolicyFactory struct {
+}
+
+func (*uploadDelayPolicyFactory) Keyword() string {
+	return uploadDelayKeyword
+}
+
+func (*uploadDelayPolicyFactory) Build(args []string) (Policy, []string, error) {
+	if len(args) == 0 {
+		return nil, args, fmt.Errorf("%s need a duration", uploadDelayKeyword)
+	}
+
+	duration, err := parseDuration(args[0])
+	if err != nil {
+		return nil, args, err
+	}
+
+	return &UploadDelayPolicy{duration}, args[1:], nil
+}
+
+func (d *UploadDelayPolicy) Keyword() string {
+	return uploadDelayKeyword
+}
+
+func (d *UploadDelayPolicy) Command() string {
+	return uploadDelayKeyword + " " + formatDuration(d.duration)
+}
+
+func (d *UploadDelayPolicy) Comment() string {
+	return "延时 " + formatDuration(d.duration) + " 后开始上传"
+}
+
+func (d *UploadDelayPolicy) Update(p Policy) error {
+	switch p := p.(type) {
+	case *UploadDelayPolicy:
+		d.duration = p.duration
+	default:
+		return fmt.Errorf("unmatch policy")
+	}
+
+	return nil
+}
+
+func (d *UploadDelayPolicy) Duration() time.Duration {
+	return time.Duration(float64(d.duration) * float64(time.Second))
+}
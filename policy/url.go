@@ -24,6 +24,8 @@ func init() {
 		rewriteKeyword,
 		restoreKeyword,
 		tcpwriteKeyword,
+		tcpscriptKeyword,
+		malformedKeyword,
 		chunkedKeyword,
 		speedKeyword,
 		dont302Keyword,
@@ -35,6 +37,21 @@ func init() {
 		responseHeadersKeyword,
 		hostKeyword,
 		pluginKeyword,
+		bodyReplaceKeyword,
+		jsonSetKeyword,
+		jsonDeleteKeyword,
+		jsonPatchKeyword,
+		requestBodyKeyword,
+		cookiesKeyword,
+		corsKeyword,
+		networkKeyword,
+		uploadSpeedKeyword,
+		uploadDelayKeyword,
+		cutKeyword,
+		corruptKeyword,
+		stallKeyword,
+		hangKeyword,
+		encodingKeyword,
 		removeKeyword,
 		deleteKeyword,
 	)
@@ -47,7 +64,7 @@ type UrlPolicy struct {
 	target   string
 	set      *SetPolicy
 	delays   Policy // drop, delay, timeout
-	contents Policy // proxy, cache, map, redirect, rewrite, restore, tcpwrite
+	contents Policy // proxy, cache, map, redirect, rewrite, restore, tcpwrite, tcpscript, malformed
 	bodys    Policy // delay body, timeout body
 	subs     []Policy
 	subKeys  map[string]Policy
@@ -128,7 +145,7 @@ func newUrlPolicy(subs []Policy, target string) (*UrlPolicy, error) {
 					u.delays = p
 				}
 			}
-		case *ProxyPolicy, *CachePolicy, *MapPolicy, *RedirectPolicy, *RewritePolicy, *RestorePolicy, *TcpwritePolicy:
+		case *ProxyPolicy, *CachePolicy, *MapPolicy, *RedirectPolicy, *RewritePolicy, *RestorePolicy, *TcpwritePolicy, *TcpscriptPolicy, *MalformedPolicy:
 			if u.contents != nil {
 				return nil, fmt.Errorf(`conflict keyword: "%s" vs "%s"`, u.contents.Command(), p.Command())
 			} else {
@@ -245,9 +262,9 @@ func (u *UrlPolicy) Update(p Policy) error {
 		} else {
 			u.delays = p
 		}
-	case *ProxyPolicy, *CachePolicy, *MapPolicy, *RedirectPolicy, *RewritePolicy, *RestorePolicy, *TcpwritePolicy:
+	case *ProxyPolicy, *CachePolicy, *MapPolicy, *RedirectPolicy, *RewritePolicy, *RestorePolicy, *TcpwritePolicy, *TcpscriptPolicy, *MalformedPolicy:
 		u.contents = p
-	case *StatusPolicy, *SpeedPolicy, *Dont302Policy, *Disable304Policy, *ContentTypePolicy, *HeadersPolicy, *HostPolicy, *ChunkedPolicy, *PluginPolicy:
+	case *StatusPolicy, *SpeedPolicy, *Dont302Policy, *Disable304Policy, *ContentTypePolicy, *HeadersPolicy, *HostPolicy, *ChunkedPolicy, *PluginPolicy, *BodyReplacePolicy, *JsonSetPolicy, *JsonDeletePolicy, *JsonPatchPolicy, *RequestBodyPolicy, *CookiesPolicy, *CorsPolicy, *NetworkPolicy, *UploadSpeedPolicy, *UploadDelayPolicy, *CutPolicy, *CorruptPolicy, *StallPolicy, *HangPolicy, *EncodingPolicy:
 		for i, s := range u.subs {
 			if s.Keyword() == p.Keyword() {
 				u.subs[i] = p
@@ -406,6 +423,126 @@ func (u *UrlPolicy) ResponseHeaders() *HeadersPolicy {
 	return nil
 }
 
+func (u *UrlPolicy) Cookies() *CookiesPolicy {
+	p := u.subKeyDef(cookiesKeyword)
+	if p != nil {
+		c, ok := p.(*CookiesPolicy)
+		if ok {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Cors() *CorsPolicy {
+	p := u.subKeyDef(corsKeyword)
+	if p != nil {
+		c, ok := p.(*CorsPolicy)
+		if ok {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Network() *NetworkPolicy {
+	p := u.subKeyDef(networkKeyword)
+	if p != nil {
+		n, ok := p.(*NetworkPolicy)
+		if ok {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) UploadSpeed() *UploadSpeedPolicy {
+	p := u.subKeyDef(uploadSpeedKeyword)
+	if p != nil {
+		s, ok := p.(*UploadSpeedPolicy)
+		if ok {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) UploadDelay() *UploadDelayPolicy {
+	p := u.subKeyDef(uploadDelayKeyword)
+	if p != nil {
+		d, ok := p.(*UploadDelayPolicy)
+		if ok {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Cut() *CutPolicy {
+	p := u.subKeyDef(cutKeyword)
+	if p != nil {
+		c, ok := p.(*CutPolicy)
+		if ok {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Corrupt() *CorruptPolicy {
+	p := u.subKeyDef(corruptKeyword)
+	if p != nil {
+		c, ok := p.(*CorruptPolicy)
+		if ok {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Stall() *StallPolicy {
+	p := u.subKeyDef(stallKeyword)
+	if p != nil {
+		s, ok := p.(*StallPolicy)
+		if ok {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Hang() *HangPolicy {
+	p := u.subKeyDef(hangKeyword)
+	if p != nil {
+		h, ok := p.(*HangPolicy)
+		if ok {
+			return h
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) Encoding() *EncodingPolicy {
+	p := u.subKeyDef(encodingKeyword)
+	if p != nil {
+		e, ok := p.(*EncodingPolicy)
+		if ok {
+			return e
+		}
+	}
+
+	return nil
+}
+
 func (u *UrlPolicy) Host() *HostPolicy {
 	p := u.subKeyDef(hostKeyword)
 	if p != nil {
@@ -442,6 +579,46 @@ func (u *UrlPolicy) Plugin() *PluginPolicy {
 	return nil
 }
 
+func (u *UrlPolicy) BodyReplace() *BodyReplacePolicy {
+	p := u.subKeyDef(bodyReplaceKeyword)
+	if p != nil {
+		b, ok := p.(*BodyReplacePolicy)
+		if ok {
+			return b
+		}
+	}
+
+	return nil
+}
+
+func (u *UrlPolicy) RequestBody() *RequestBodyPolicy {
+	p := u.subKeyDef(requestBodyKeyword)
+	if p != nil {
+		b, ok := p.(*RequestBodyPolicy)
+		if ok {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// JsonEditors returns json-patch, json-set and json-delete in order.
+func (u *UrlPolicy) JsonEditors() []JsonEditor {
+	editors := make([]JsonEditor, 0)
+	for _, key := range []string{jsonPatchKeyword, jsonSetKeyword, jsonDeleteKeyword} {
+		p := u.subKeyDef(key)
+		if p != nil {
+			e, ok := p.(JsonEditor)
+			if ok {
+				editors = append(editors, e)
+			}
+		}
+	}
+
+	return editors
+}
+
 func (u *UrlPolicy) Delete() bool {
 	_, ok := u.subKeys[deleteKeyword]
 	return ok
@@ -521,7 +698,7 @@ func (u *UrlPolicy) remove(r *RemovePolicy) {
 		if u.delays != nil && u.delays.Keyword() == keyword {
 			u.delays = nil
 		}
-	case proxyKeyword, cacheKeyword, mapKeyword, redirectKeyword, rewriteKeyword, restoreKeyword, tcpwriteKeyword:
+	case proxyKeyword, cacheKeyword, mapKeyword, redirectKeyword, rewriteKeyword, restoreKeyword, tcpwriteKeyword, tcpscriptKeyword, malformedKeyword:
 		if u.contents != nil && u.contents.Keyword() == keyword {
 			u.contents = nil
 		}
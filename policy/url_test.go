@@ -1,6 +1,10 @@
 package policy
 
-import "testing"
+import (
+	"net/http"
+	"testing"
+	"time"
+)
 
 func TestUrlPolicy(t *testing.T) {
 	cmd := "url speed 1B/s g.cn"
@@ -136,3 +140,226 @@ func TestUrlPolicy(t *testing.T) {
 		}
 	}
 }
+
+func TestUrlBodyReplacePolicy(t *testing.T) {
+	cmd := "url body-replace /price=[0-9]+/price=0/g speed 1KB/s g.cn/shop"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Errorf("url(%s) failed: %v", cmd, err)
+		return
+	} else if u.Command() != cmd {
+		t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+	}
+
+	br := u.BodyReplace()
+	if br == nil {
+		t.Errorf("url(%s) missed body-replace policy", cmd)
+	} else if c := string(br.Replace([]byte("a&price=12&price=3"))); c != "a&price=0&price=0" {
+		t.Errorf("url(%s).BodyReplace().Replace() wrong: %s", cmd, c)
+	}
+
+	cmd = "url body-replace `/100% off/free/` g.cn/shop"
+	if u, err := FactoryUrl(cmd); err != nil || u.Command() != cmd {
+		t.Errorf("url(%s) should round-trip: %v", cmd, err)
+	} else if c := string(u.BodyReplace().Replace([]byte("100% off"))); c != "free" {
+		t.Errorf("url(%s).BodyReplace().Replace() wrong: %s", cmd, c)
+	}
+
+	cmd = "url body-replace /a/b/x g.cn"
+	if _, err := FactoryUrl(cmd); err == nil {
+		t.Errorf("url(%s) should fail", cmd)
+	}
+}
+
+func TestUrlUploadPolicy(t *testing.T) {
+	cmd := "url upload-speed 8KB/s upload-delay 1.5s g.cn/upload"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Errorf("url(%s) failed: %v", cmd, err)
+		return
+	} else if u.Command() != cmd {
+		t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+	}
+
+	if s := u.UploadSpeed(); s == nil || s.Speed() != 8*1024 {
+		t.Errorf("url(%s).UploadSpeed() wrong: %v", cmd, s)
+	}
+
+	if d := u.UploadDelay(); d == nil || d.Duration() != 1500*time.Millisecond {
+		t.Errorf("url(%s).UploadDelay() wrong: %v", cmd, d)
+	}
+
+	if u.Speed() != nil {
+		t.Errorf("url(%s) should not have speed policy", cmd)
+	}
+
+	cmd = "url upload-speed 0 g.cn"
+	if _, err := FactoryUrl(cmd); err == nil {
+		t.Errorf("url(%s) should fail", cmd)
+	}
+}
+
+func TestUrlFaultPolicy(t *testing.T) {
+	cmd := "url cut 50% corrupt 0.1% stall 8KB 2s g.cn/download"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Errorf("url(%s) failed: %v", cmd, err)
+		return
+	} else if u.Command() != cmd {
+		t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+	}
+
+	if c := u.Cut(); c == nil || !c.Percent() || c.Offset(1000) != 500 || c.Offset(-1) != -1 {
+		t.Errorf("url(%s).Cut() wrong: %v", cmd, c)
+	}
+
+	if c := u.Corrupt(); c == nil || c.Rate() != 0.001 {
+		t.Errorf("url(%s).Corrupt() wrong: %v", cmd, c)
+	}
+
+	if s := u.Stall(); s == nil || s.Percent() || s.Offset(-1) != 8192 || s.Duration() != 2*time.Second {
+		t.Errorf("url(%s).Stall() wrong: %v", cmd, s)
+	}
+
+	for _, cmd := range []string{"url cut 120% g.cn", "url cut x g.cn", "url corrupt 2 g.cn", "url stall 10 g.cn"} {
+		if _, err := FactoryUrl(cmd); err == nil {
+			t.Errorf("url(%s) should fail", cmd)
+		}
+	}
+}
+
+func TestUrlHangPolicy(t *testing.T) {
+	check := func(cmd string, partial bool, d time.Duration) {
+		u, err := FactoryUrl(cmd)
+		if err != nil {
+			t.Errorf("url(%s) failed: %v", cmd, err)
+			return
+		} else if u.Command() != cmd {
+			t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+		}
+
+		if h := u.Hang(); h == nil || h.Partial() != partial || h.Duration() != d {
+			t.Errorf("url(%s).Hang() wrong: %v", cmd, h)
+		} else if u.Target() != "g.cn/slow" {
+			t.Errorf("url(%s).Target() wrong: %s", cmd, u.Target())
+		}
+	}
+
+	check("url hang g.cn/slow", false, 0)
+	check("url hang 30s g.cn/slow", false, 30*time.Second)
+	check("url hang partial g.cn/slow", true, 0)
+	check("url hang partial 1m g.cn/slow", true, time.Minute)
+}
+
+func TestUrlEncodingPolicy(t *testing.T) {
+	cmd := "url encoding br g.cn/api"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Fatalf("url(%s) failed: %v", cmd, err)
+	} else if u.Command() != cmd {
+		t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+	}
+
+	if e := u.Encoding(); e == nil || e.Encoding() != EncodingBrotli {
+		t.Errorf("url(%s).Encoding() wrong: %v", cmd, e)
+	}
+
+	ex, _ := FactoryUrl("url encoding identity g.cn/api")
+	if err := u.Update(ex); err != nil {
+		t.Errorf("url(%s).Update() failed: %v", cmd, err)
+	} else if e := u.Encoding(); e == nil || e.Encoding() != EncodingIdentity {
+		t.Errorf("url(%s).Encoding() not updated: %v", cmd, e)
+	}
+
+	if _, err := FactoryUrl("url encoding zip g.cn"); err == nil {
+		t.Errorf("url(encoding zip) should fail")
+	}
+}
+
+func TestUrlCachePolicy(t *testing.T) {
+	check := func(cmd string, disk, shared bool, ttl time.Duration) {
+		u, err := FactoryUrl(cmd)
+		if err != nil {
+			t.Errorf("url(%s) failed: %v", cmd, err)
+			return
+		} else if u.Command() != cmd {
+			t.Errorf("url(%s).Command() changed: %s", cmd, u.Command())
+		}
+
+		c, ok := u.ContentPolicy().(*CachePolicy)
+		if !ok || c.Disk() != disk || c.Shared() != shared || c.TTL() != ttl {
+			t.Errorf("url(%s).ContentPolicy() wrong: %v", cmd, u.ContentPolicy())
+		} else if u.Target() != "g.cn/static" {
+			t.Errorf("url(%s).Target() wrong: %s", cmd, u.Target())
+		}
+	}
+
+	check("url cache g.cn/static", false, false, 0)
+	check("url cache ttl 24h g.cn/static", true, false, 24*time.Hour)
+	check("url cache shared g.cn/static", true, true, 0)
+	check("url cache ttl 30m shared g.cn/static", true, true, 30*time.Minute)
+
+	for _, cmd := range []string{"url cache ttl g.cn", "url cache ttl 0 g.cn", "url cache ttl x g.cn"} {
+		if _, err := FactoryUrl(cmd); err == nil {
+			t.Errorf("url(%s) should fail", cmd)
+		}
+	}
+}
+
+func TestUrlCacheKey(t *testing.T) {
+	cmd := "url cache key method,header:authorization,body-hash g.cn/graphql"
+	u, err := FactoryUrl(cmd)
+	if err != nil {
+		t.Fatalf("url(%s) failed: %v", cmd, err)
+	} else if u.Command() != "url cache key method,header:Authorization,body-hash g.cn/graphql" {
+		t.Errorf("url(%s).Command() wrong: %s", cmd, u.Command())
+	}
+
+	c := u.ContentPolicy().(*CachePolicy)
+	if !c.AnyMethod() || !c.NeedBody() || c.Disk() {
+		t.Errorf("url(%s) options wrong: %v", cmd, c)
+	}
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer a")
+	k1 := c.Key("http://g.cn/graphql?t=1", "POST", h, []byte(`{"q":1}`))
+	k2 := c.Key("http://g.cn/graphql?t=2", "POST", h, []byte(`{"q":1}`))
+	if k1 == k2 {
+		t.Errorf("url(%s) key should keep query: %s vs %s", cmd, k1, k2)
+	}
+
+	if k := c.Key("http://g.cn/graphql?t=1", "POST", h, []byte(`{"q":2}`)); k == k1 {
+		t.Errorf("url(%s) key should differ by body: %s", cmd, k)
+	}
+
+	if k := c.Key("http://g.cn/graphql?t=1", "GET", h, []byte(`{"q":1}`)); k == k1 {
+		t.Errorf("url(%s) key should differ by method: %s", cmd, k)
+	}
+
+	h.Set("Authorization", "Bearer b")
+	if k := c.Key("http://g.cn/graphql?t=1", "POST", h, []byte(`{"q":1}`)); k == k1 {
+		t.Errorf("url(%s) key should differ by header: %s", cmd, k)
+	}
+
+	u, _ = FactoryUrl("url cache ttl 1h key query g.cn/a")
+	c = u.ContentPolicy().(*CachePolicy)
+	if k1, k2 := c.Key("http://g.cn/a?x=1", "GET", nil, nil), c.Key("http://g.cn/a?x=2", "GET", nil, nil); k1 == k2 || c.AnyMethod() {
+		t.Errorf("cache key query wrong: %s vs %s", k1, k2)
+	}
+
+	u, _ = FactoryUrl("url cache key -query,method g.cn/a")
+	c = u.ContentPolicy().(*CachePolicy)
+	if k1, k2 := c.Key("http://g.cn/a?x=1", "POST", nil, nil), c.Key("http://g.cn/a?x=2", "POST", nil, nil); k1 != k2 {
+		t.Errorf("cache key -query should ignore query: %s vs %s", k1, k2)
+	}
+
+	if u, _ := FactoryUrl("url cache g.cn/a"); u.ContentPolicy().(*CachePolicy).Key("http://g.cn/a?x=1", "GET", nil, nil) != "http://g.cn/a?x=1" {
+		t.Errorf("cache default key should be url")
+	}
+
+	for _, cmd := range []string{"url cache key g.cn", "url cache key cookie g.cn", "url cache key header: g.cn", "url cache key query,-query g.cn"} {
+		if _, err := FactoryUrl(cmd); err == nil {
+			t.Errorf("url(%s) should fail", cmd)
+		}
+	}
+}
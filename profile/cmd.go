@@ -30,6 +30,30 @@ func (p *Profile) ExportCommand() string {
 		export += "\n# URL 缺省配置\n" + p.UrlDefault.Command() + "\n"
 	}
 
+	if n := p.NetworkPolicy(); n != nil {
+		export += "\n# 网络状况\n" + n.Command() + "\n"
+	}
+
+	if b := p.BandwidthPolicy(); b != nil {
+		export += "\n# 设备带宽\n" + b.Command() + "\n"
+	}
+
+	if o := p.OfflinePolicy(); o != nil {
+		export += "\n# 离线模式\n" + o.Command() + "\n"
+	}
+
+	if r := p.RecordPolicy(); r != nil {
+		export += "\n# 录制会话\n" + r.Command() + "\n"
+	}
+
+	if r := p.ReplayPolicy(); r != nil {
+		export += "\n# 回放会话\n" + r.Command() + "\n"
+	}
+
+	if h := p.HistoryPolicy(); h != nil {
+		export += "\n# 历史限制\n" + h.Command() + "\n"
+	}
+
 	export += "\n# 以下为 URL 命令定义 #\n"
 	for _, u := range p.Urls {
 		export += u.p.Command() + "\n"
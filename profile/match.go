@@ -35,6 +35,24 @@ func NewDomainPattern(pattern string) *DomainPattern {
 }
 
 func domainPattern2Regex(pattern string) string {
+	return domainPatternRegex(pattern, false)
+}
+
+// capture == true makes every `*' a capturing group, and others not.
+func domainPatternRegex(pattern string, capture bool) string {
+	group := func(r string) string {
+		if capture {
+			return "(" + r + ")"
+		} else {
+			return r
+		}
+	}
+
+	inner := "("
+	if capture {
+		inner = "(?:"
+	}
+
 	pattern = strings.TrimSpace(pattern)
 	if strings.HasSuffix(pattern, ".") {
 		pattern = pattern[0 : len(pattern)-1]
@@ -48,14 +66,14 @@ func domainPattern2Regex(pattern string) string {
 			// ".." ? just pass
 		} else if p == "*" {
 			if i+1 == len(dots) {
-				r += "[^.]+"
+				r += group("[^.]+")
 			} else {
 				rep := "+"
 				if len(r) == 0 {
 					rep = "*"
 				}
 
-				r += "([^.]+\\.)" + rep
+				r += group(inner + "[^.]+\\.)" + rep)
 			}
 		} else if strings.Index(p, "*") >= 0 {
 			suffix := "\\."
@@ -63,7 +81,7 @@ func domainPattern2Regex(pattern string) string {
 				suffix = ""
 			}
 
-			r += strings.Replace(p, "*", "[^.]*", -1) + suffix
+			r += strings.Replace(p, "*", group("[^.]*"), -1) + suffix
 		} else {
 			suffix := "\\."
 			if i+1 == len(dots) {
@@ -145,6 +163,18 @@ func uniqueTrim(s string, u rune) string {
 }
 
 func pathPattern2Regex(pattern string) string {
+	return pathPatternRegex(pattern, false)
+}
+
+func pathPatternRegex(pattern string, capture bool) string {
+	group := func(r string) string {
+		if capture {
+			return "(" + r + ")"
+		} else {
+			return r
+		}
+	}
+
 	pattern = uniqueTrim(strings.TrimSpace(pattern), '/')
 
 	r := ""
@@ -159,12 +189,20 @@ func pathPattern2Regex(pattern string) string {
 		} else if p == "*" {
 			if i+1 == len(nodes) {
 				// end with `/*'
-				r += "/([^/]+/)*[^/]*"
+				if capture {
+					r += "/((?:[^/]+/)*[^/]*)"
+				} else {
+					r += "/([^/]+/)*[^/]*"
+				}
 			} else {
-				r += "(/[^/]+)+"
+				if capture {
+					r += "/([^/]+(?:/[^/]+)*)"
+				} else {
+					r += "(/[^/]+)+"
+				}
 			}
 		} else if strings.Index(p, "*") >= 0 {
-			r += "/" + strings.Replace(p, "*", "[^/]*", -1)
+			r += "/" + strings.Replace(p, "*", group("[^/]*"), -1)
 		} else {
 			r += "/" + p
 		}
@@ -313,17 +351,54 @@ func (p *UrlPattern) MatchUrlScore(url string) uint32 {
 	return p.MatchScore(parseUrlSection(url))
 }
 
+// Captures returns what each `*' of the domain and path pattern matched,
+// or nil if url doesn't match.
+func (p *UrlPattern) Captures(url string) []string {
+	us := parseUrlSection(url)
+	if !p.Match(us) {
+		return nil
+	}
+
+	captures := make([]string, 0)
+	capture := func(regex, s string) {
+		r, err := regexp.Compile(regex)
+		if err != nil {
+			return
+		}
+
+		m := r.FindStringSubmatch(s)
+		if len(m) > 1 {
+			captures = append(captures, m[1:]...)
+		}
+	}
+
+	if p.domain != nil && p.domain.regex != nil {
+		capture(domainPatternRegex(p.domain.pattern, true), us.domain)
+	}
+
+	if p.path.regex != nil {
+		path := us.path
+		if path == "" {
+			path = "/"
+		}
+
+		pattern := p.path.pattern
+		if pattern == "" {
+			pattern = "/*"
+		}
+
+		capture(pathPatternRegex(pattern, true), path)
+	}
+
+	return captures
+}
+
 func parseUrlAsPattern(url string) [5]string {
 	scheme := "http"
-	sp := strings.IndexByte(url, ':')
-	if sp > 0 {
+	sp := strings.Index(url, "://")
+	if sp > 0 && strings.IndexByte(url[:sp], '/') < 0 {
 		scheme = url[:sp]
-		sp++
-		for sp < len(url) && url[sp] == '/' {
-			sp++
-		}
-
-		url = url[sp:]
+		url = url[sp+3:]
 	}
 
 	head := ""
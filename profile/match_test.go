@@ -207,3 +207,42 @@ func TestPathPatternRegex(t *testing.T) {
 	f("/*/*/*.jpg", "^(/[^/]+)+(/[^/]+)+/[^/]*.jpg$")
 	f("/*/p/*/*.jpg", "^(/[^/]+)+/p(/[^/]+)+/[^/]*.jpg$")
 }
+
+func TestUrlPatternCaptures(t *testing.T) {
+	f := func(p, url string, c []string) {
+		x := NewUrlPattern(p).Captures(url)
+		if len(x) != len(c) || (c == nil) != (x == nil) {
+			t.Errorf("%s captures %s -> %v != %v", p, url, x, c)
+			return
+		}
+
+		for i := range c {
+			if x[i] != c[i] {
+				t.Errorf("%s captures %s -> %v != %v", p, url, x, c)
+				return
+			}
+		}
+	}
+
+	f("domain.com/a", "domain.com/b", nil)
+	f("domain.com/a", "domain.com/a", []string{})
+	f("domain.com/user/*", "domain.com/user/12/info", []string{"12/info"})
+	f("domain.com/user/*/info", "domain.com/user/12/info", []string{"12"})
+	f("domain.com/p/*.jpg", "domain.com/p/cat.jpg", []string{"cat"})
+	f("*.domain.com/p/*.jpg", "cdn.domain.com/p/cat.jpg", []string{"cdn.", "cat"})
+	f("cdn*.domain.com/*", "cdn-1.domain.com/x?y=z", []string{"-1", "x"})
+}
+
+func TestUrlPatternPort(t *testing.T) {
+	f := func(p, url string, match bool) {
+		if NewUrlPattern(p).MatchUrl(url) != match {
+			t.Errorf("%s match %s should be %v", p, url, match)
+		}
+	}
+
+	f("domain.com:8080/a", "http://domain.com:8080/a", true)
+	f("domain.com:8080/a", "domain.com:8080/a", true)
+	f("domain.com:8080/a", "http://domain.com/a", false)
+	f("127.0.0.1:8001/a", "http://127.0.0.1:8001/a?b=1", true)
+	f("https://domain.com:8443/a", "https://domain.com:8443/a", true)
+}
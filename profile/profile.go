@@ -64,6 +64,13 @@ type Profile struct {
 	saver      *ProfileRootDir
 	notSet     bool
 
+	network   *policy.NetworkPolicy
+	bandwidth *policy.BandwidthPolicy
+	offline   *policy.OfflinePolicy
+	record    *policy.RecordPolicy
+	replay    *policy.ReplayPolicy
+	history   *policy.HistoryPolicy
+
 	proxyOp ProxyHostOperator
 
 	accessCode string
@@ -427,6 +434,12 @@ func (p *Profile) CloneNew(newName, newIp, newOwner string) *Profile {
 		n.stores[s] = &c
 	}
 
+	n.network = p.network
+	n.bandwidth = p.bandwidth
+	n.offline = p.offline
+	n.record = p.record
+	n.replay = p.replay
+	n.history = p.history
 	return n
 }
 
@@ -435,6 +448,132 @@ func (p *Profile) Clear() {
 	p.DeleteAllDomain()
 	p.storeID = 1
 	p.DeleteAllStore()
+	p.SetNetworkPolicy(nil)
+	p.SetBandwidthPolicy(nil)
+	p.SetOfflinePolicy(nil)
+	p.SetRecordPolicy(nil)
+	p.SetReplayPolicy(nil)
+	p.SetHistoryPolicy(nil)
+}
+
+// SetNetworkPolicy sets the network condition of whole profile,
+// `network off' or nil to remove it.
+func (p *Profile) SetNetworkPolicy(n *policy.NetworkPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if n != nil && n.Off() {
+		n = nil
+	}
+
+	p.network = n
+}
+
+func (p *Profile) NetworkPolicy() *policy.NetworkPolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.network
+}
+
+// SetBandwidthPolicy sets the bandwidth shared by all connections of the
+// device, `bandwidth off' or nil to remove it.
+func (p *Profile) SetBandwidthPolicy(b *policy.BandwidthPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if b != nil && b.Off() {
+		b = nil
+	}
+
+	p.bandwidth = b
+}
+
+func (p *Profile) BandwidthPolicy() *policy.BandwidthPolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.bandwidth
+}
+
+// SetOfflinePolicy makes the device answered from recorded traffic,
+// `offline off' or nil to go online.
+func (p *Profile) SetOfflinePolicy(o *policy.OfflinePolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if o != nil && o.Off() {
+		o = nil
+	}
+
+	p.offline = o
+}
+
+func (p *Profile) OfflinePolicy() *policy.OfflinePolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.offline
+}
+
+// SetRecordPolicy records proxied exchanges of the device to a session,
+// `record off' or nil to stop.
+func (p *Profile) SetRecordPolicy(r *policy.RecordPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if r != nil && r.Off() {
+		r = nil
+	}
+
+	p.record = r
+}
+
+func (p *Profile) RecordPolicy() *policy.RecordPolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.record
+}
+
+// SetReplayPolicy answers the device from a recorded session, `replay off'
+// or nil to stop.
+func (p *Profile) SetReplayPolicy(r *policy.ReplayPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if r != nil && r.Off() {
+		r = nil
+	}
+
+	p.replay = r
+}
+
+func (p *Profile) ReplayPolicy() *policy.ReplayPolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.replay
+}
+
+// SetHistoryPolicy limits histories of the device in memory, `history off'
+// or nil for the global limits.
+func (p *Profile) SetHistoryPolicy(h *policy.HistoryPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if h != nil && h.Off() {
+		h = nil
+	}
+
+	p.history = h
+}
+
+func (p *Profile) HistoryPolicy() *policy.HistoryPolicy {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.history
 }
 
 func (p *Profile) AccessCode() string {
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/profile"
+
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenBucket is shared by all connections of a device. tokens may be
+// negative, then the next taker waits longer, which keeps the total speed.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64 // B/s, 0 for no limit
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) setRate(rate float32) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if float64(rate) != b.rate {
+		b.rate = float64(rate)
+		b.tokens = 0
+		b.last = time.Now()
+	}
+}
+
+// chunk returns how many bytes should be taken once, to let connections
+// take turns.
+func (b *tokenBucket) chunk(n int) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.rate <= 0 {
+		return n
+	}
+
+	max := int(b.rate / 8)
+	if max < 1 {
+		max = 1
+	}
+
+	if n > max {
+		return max
+	}
+
+	return n
+}
+
+func (b *tokenBucket) take(n int) {
+	b.lock.Lock()
+	if b.rate <= 0 {
+		b.lock.Unlock()
+		return
+	}
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+
+	// burst at most 1/4s of data
+	if burst := b.rate / 4; b.tokens > burst {
+		b.tokens = burst
+	}
+
+	b.tokens -= float64(n)
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+
+	b.lock.Unlock()
+
+	if d > 0 {
+		<-time.NewTimer(d).C
+	}
+}
+
+func (b *tokenBucket) write(w io.Writer, p []byte) (int, error) {
+	sum := 0
+	for len(p) > 0 {
+		c := b.chunk(len(p))
+		b.take(c)
+		n, err := w.Write(p[:c])
+		sum += n
+		if err != nil {
+			return sum, err
+		}
+
+		p = p[n:]
+	}
+
+	return sum, nil
+}
+
+func (b *tokenBucket) read(r io.Reader, p []byte) (int, error) {
+	if len(p) > 0 {
+		p = p[:b.chunk(len(p))]
+	}
+
+	n, err := r.Read(p)
+	if n > 0 {
+		b.take(n)
+	}
+
+	return n, err
+}
+
+type deviceBandwidth struct {
+	down tokenBucket
+	up   tokenBucket
+}
+
+// bandwidth returns the buckets of device ip, or nil if it has no
+// `bandwidth' setting.
+func (p *Proxy) bandwidth(ip string, prof *profile.Profile) *deviceBandwidth {
+	if prof == nil {
+		if p.isSelfAddr(ip) || p.profileOp == nil {
+			return nil
+		}
+
+		prof = p.profileOp.FindByIp(ip)
+	}
+
+	if prof == nil {
+		return nil
+	}
+
+	bp := prof.BandwidthPolicy()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if bp == nil {
+		delete(p.bandwidths, ip)
+		return nil
+	}
+
+	b, ok := p.bandwidths[ip]
+	if !ok {
+		b = new(deviceBandwidth)
+		p.bandwidths[ip] = b
+	}
+
+	b.down.setRate(bp.Down())
+	b.up.setRate(bp.Up())
+	return b
+}
+
+type bandwidthResponseWriter struct {
+	w http.ResponseWriter
+	b *deviceBandwidth
+}
+
+func (b *deviceBandwidth) wrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	return &bandwidthResponseWriter{w, b}
+}
+
+func (w *bandwidthResponseWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w *bandwidthResponseWriter) WriteHeader(code int) {
+	w.w.WriteHeader(code)
+}
+
+func (w *bandwidthResponseWriter) Write(p []byte) (int, error) {
+	return w.b.down.write(w.w, p)
+}
+
+func (w *bandwidthResponseWriter) Flush() {
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *bandwidthResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+
+	conn = w.b.wrapConn(conn)
+	r := w.b.wrapBody(ioutil.NopCloser(rw.Reader))
+	return conn, bufio.NewReadWriter(bufio.NewReader(r), bufio.NewWriter(conn)), nil
+}
+
+type bandwidthBody struct {
+	r io.ReadCloser
+	b *deviceBandwidth
+}
+
+func (b *deviceBandwidth) wrapBody(r io.ReadCloser) io.ReadCloser {
+	return &bandwidthBody{r, b}
+}
+
+func (r *bandwidthBody) Read(p []byte) (int, error) {
+	return r.b.up.read(r.r, p)
+}
+
+func (r *bandwidthBody) Close() error {
+	return r.r.Close()
+}
+
+// bandwidthConn is the client side of a connection, reading from which is
+// upstream and writing to which is downstream.
+type bandwidthConn struct {
+	net.Conn
+	b *deviceBandwidth
+}
+
+func (b *deviceBandwidth) wrapConn(conn net.Conn) net.Conn {
+	return &bandwidthConn{conn, b}
+}
+
+func (c *bandwidthConn) Read(p []byte) (int, error) {
+	return c.b.up.read(c.Conn, p)
+}
+
+func (c *bandwidthConn) Write(p []byte) (int, error) {
+	return c.b.down.write(c.Conn, p)
+}
+
+func (c *bandwidthConn) CloseWrite() error {
+	if w, ok := c.Conn.(interface {
+		CloseWrite() error
+	}); ok {
+		return w.CloseWrite()
+	}
+
+	return c.Conn.Close()
+}
+
+func (c *bandwidthConn) SetLinger(sec int) error {
+	if l, ok := c.Conn.(interface {
+		SetLinger(sec int) error
+	}); ok {
+		return l.SetLinger(sec)
+	}
+
+	return nil
+}
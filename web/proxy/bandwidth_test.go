@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"testing"
+)
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+func TestTokenBucketShared(t *testing.T) {
+	b := new(deviceBandwidth)
+	b.down.setRate(40 * 1024)
+	if c := b.down.chunk(100000); c != 5*1024 {
+		t.Errorf("chunk(100000) return %d vs %d", c, 5*1024)
+	}
+
+	data := make([]byte, 10*1024)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := b.down.write(ioutil.Discard, data)
+			if n != len(data) || err != nil {
+				t.Errorf("write() return %d, %v", n, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// 20KB at 40KB/s shared
+	if d := time.Now().Sub(start); d < 400*time.Millisecond || d > 900*time.Millisecond {
+		t.Errorf("shared write 20KB at 40KB/s in %v", d)
+	}
+
+	up := new(deviceBandwidth)
+	r := up.wrapBody(ioutil.NopCloser(bytes.NewReader(data)))
+	if body, err := ioutil.ReadAll(r); err != nil || len(body) != len(data) {
+		t.Errorf("unlimited read return %d, %v", len(body), err)
+	}
+}
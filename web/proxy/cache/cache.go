@@ -3,15 +3,12 @@ package cache
 import (
 	"github.com/benbearchen/asuran/net"
 
-	"bytes"
-	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -29,6 +26,22 @@ type UrlCache struct {
 	ResponseCode   int
 	RangeInfo      string
 	Error          error
+	Warnings       []string
+
+	OriginalPostBody []byte
+	UploadDelay      time.Duration
+	UploadDuration   time.Duration
+
+	// Key is the cache key made by policy, empty for Url. KeyHeader is
+	// the request header before edits, to tell Vary, nil for RequestHeader.
+	Key       string
+	KeyHeader http.Header
+
+	// Policy is the command of url policy applied, empty for none.
+	Policy string
+
+	// ResendOf is the ID of history which this is resent from, 0 for none.
+	ResendOf uint32
 }
 
 type UrlHistory struct {
@@ -45,7 +58,19 @@ func NewUrlCache(url string, r *http.Request, postBody []byte, resp *net.HttpRes
 		respResponseCode = resp.ResponseCode()
 	}
 
-	return &UrlCache{start, end.Sub(start), url, r.Method, r.Header, postBody, contentSource, content, respHeader, respResponseCode, rangeInfo, err}
+	return &UrlCache{start, end.Sub(start), url, r.Method, r.Header, postBody, contentSource, content, respHeader, respResponseCode, rangeInfo, err, nil, nil, 0, 0, "", nil, "", ResendOf(r)}
+}
+
+type resendKey struct{}
+
+// WithResendOf marks r as resent from the history of id.
+func WithResendOf(r *http.Request, id uint32) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), resendKey{}, id))
+}
+
+func ResendOf(r *http.Request) uint32 {
+	id, _ := r.Context().Value(resendKey{}).(uint32)
+	return id
 }
 
 func (c *UrlCache) Response(w http.ResponseWriter, wrap io.Writer) {
@@ -81,14 +106,35 @@ func (c *UrlCache) Detail(w http.ResponseWriter) {
 
 	t += "}}}\n"
 
-	if c.Method == "POST" && c.PostBody != nil {
-		t += "POST DATA: " + text(c.PostBody) + "\n"
+	if c.OriginalPostBody != nil {
+		t += "ORIGINAL " + c.Method + " DATA: " + text(c.OriginalPostBody) + "\n"
+	}
+
+	if c.PostBody != nil {
+		t += c.Method + " DATA: " + text(c.PostBody) + "\n"
+	}
+
+	if c.UploadDuration > 0 {
+		t += "Upload: " + c.UploadDuration.String()
+		if c.UploadDelay > 0 {
+			t += " (delay " + c.UploadDelay.String() + ")"
+		}
+
+		t += "\n"
 	}
 
 	if len(c.ContentSource) > 0 {
 		t += "\nResource: " + c.ContentSource + "\n"
 	}
 
+	if len(c.Policy) > 0 {
+		t += "Policy: " + c.Policy + "\n"
+	}
+
+	if c.ResendOf > 0 {
+		t += "ResendOf: #" + strconv.FormatUint(uint64(c.ResendOf), 10) + "\n"
+	}
+
 	t += "\n"
 
 	t += "ResponseCode: " + strconv.Itoa(c.ResponseCode) + "\n"
@@ -97,6 +143,10 @@ func (c *UrlCache) Detail(w http.ResponseWriter) {
 		t += "err: " + fmt.Sprintf("%v", c.Error) + "\n"
 	}
 
+	for _, warning := range c.Warnings {
+		t += "warning: " + warning + "\n"
+	}
+
 	t += "\nResponseHeaders: {{{\n"
 	for k, v := range c.ResponseHeader {
 		for _, v := range v {
@@ -115,24 +165,41 @@ func (c *UrlCache) Detail(w http.ResponseWriter) {
 	fmt.Fprintln(w, t)
 }
 
+func (c *UrlCache) cacheKey() string {
+	if len(c.Key) > 0 {
+		return c.Key
+	}
+
+	return c.Url
+}
+
+func (c *UrlCache) keyHeader() http.Header {
+	if c.KeyHeader != nil {
+		return c.KeyHeader
+	}
+
+	return c.RequestHeader
+}
+
 func (c *UrlCache) Content() ([]byte, error) {
 	if c.Error != nil {
 		return nil, c.Error
 	} else if len(c.Bytes) <= 0 {
 		return c.Bytes, nil
-	} else if c.ResponseHeader.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(bytes.NewBuffer(c.Bytes))
-		if err != nil {
-			return nil, err
-		}
-
-		defer reader.Close()
-		return ioutil.ReadAll(reader)
 	} else {
-		return c.Bytes, nil
+		return net.DecodeContent(c.ResponseHeader.Get("Content-Encoding"), c.Bytes)
 	}
 }
 
+// RequestBody is the body from client, before edits of request-body.
+func (c *UrlCache) RequestBody() []byte {
+	if c.OriginalPostBody != nil {
+		return c.OriginalPostBody
+	}
+
+	return c.PostBody
+}
+
 func isASCII(t []byte) bool {
 	for _, b := range t {
 		if b <= 6 { // ascii 0~6
@@ -159,9 +226,15 @@ func text(t []byte) string {
 
 type Cache struct {
 	contents map[string]UrlCache
+	varies   map[string][]string
+	merger   *rangeMerger
 	urlIds   map[string][]uint32
 	id       uint32
-	indexes  []*UrlHistory
+	indexes  []*UrlHistory // in memory, from id of first
+	first    uint32
+	size     int64
+	usage    HistoryUsage
+	spillDir string
 }
 
 func NewCache() *Cache {
@@ -177,7 +250,15 @@ func (c *Cache) historyID() uint32 {
 
 func (c *Cache) Save(cache *UrlCache, save bool) uint32 {
 	if save {
-		c.contents[cache.RangeInfo+" <> "+cache.Url] = *cache
+		if names, ok := varyNames(cache.ResponseHeader); ok {
+			key := cache.cacheKey()
+			suffix := varySuffix(names, cache.keyHeader())
+			c.varies[key] = names
+			c.contents[cache.RangeInfo+" <> "+key+suffix] = *cache
+			if full := c.merger.add(key+suffix, cache); full != nil {
+				c.contents[" <> "+key+suffix] = *full
+			}
+		}
 	}
 
 	id := c.historyID()
@@ -187,13 +268,17 @@ func (c *Cache) Save(cache *UrlCache, save bool) uint32 {
 	}
 
 	c.urlIds[cache.Url] = append(ids, id)
-	c.indexes = append(c.indexes, &UrlHistory{*cache, id})
+	h := &UrlHistory{*cache, id}
+	c.indexes = append(c.indexes, h)
+	c.size += historySize(h)
 	return id
 }
 
-func (c *Cache) Take(url, rangeInfo string) *UrlCache {
-	if content, ok := c.contents[rangeInfo+" <> "+url]; ok {
-		if content.RangeInfo == rangeInfo {
+// Take finds content by key, which is the url by default, and tells
+// contents apart by header if the response has Vary.
+func (c *Cache) Take(key, rangeInfo string, header http.Header) *UrlCache {
+	if content, ok := c.contents[rangeInfo+" <> "+key+varySuffix(c.varies[key], header)]; ok {
+		if content.RangeInfo == rangeInfo && (len(rangeInfo) == 0 || MatchIfRange(header, content.ResponseHeader)) {
 			return &content
 		}
 	}
@@ -202,35 +287,7 @@ func (c *Cache) Take(url, rangeInfo string) *UrlCache {
 		return nil
 	}
 
-	uc := c.Take(url, "")
-	if uc == nil || uc.Error != nil {
-		return nil
-	}
-
-	fc, err := uc.Content()
-	if err != nil {
-		return nil
-	}
-
-	cc, info, err := MakeRange(rangeInfo, fc)
-	if err != nil {
-		return nil
-	}
-
-	rc := *uc
-	rc.Bytes = cc
-	rc.ResponseHeader = make(map[string][]string)
-	for k, vv := range uc.ResponseHeader {
-		for _, v := range vv {
-			rc.ResponseHeader.Add(k, v)
-		}
-	}
-
-	rc.ResponseHeader.Set("Content-Range", "bytes "+info)
-	rc.ResponseHeader.Set("Content-Length", strconv.Itoa(len(cc)))
-	rc.ResponseCode = 206
-	rc.RangeInfo = rangeInfo
-	return &rc
+	return takeRange(c.Take(key, "", header), rangeInfo, header)
 }
 
 func (c *Cache) Look(url string) *UrlCache {
@@ -266,65 +323,48 @@ func (c *Cache) LastHistory(url string) *UrlHistory {
 	return nil
 }
 
+// Find returns the history of the highest score, the most recent one for
+// a tie. Histories of score <= 0 never match.
+func (c *Cache) Find(score func(h *UrlHistory) int) *UrlHistory {
+	var found *UrlHistory
+	best := 0
+	for i := len(c.indexes) - 1; i >= 0; i-- {
+		h := c.indexes[i]
+		if s := score(h); s > best {
+			found, best = h, s
+		}
+	}
+
+	return found
+}
+
+// Histories returns all histories in memory in order.
+func (c *Cache) Histories() []*UrlHistory {
+	h := make([]*UrlHistory, len(c.indexes))
+	copy(h, c.indexes)
+	return h
+}
+
+// History returns the history of id, which is loaded from disk if spilled.
 func (c *Cache) History(id uint32) *UrlHistory {
-	if uint32(len(c.indexes)) < id {
+	if id == 0 || id >= c.first+uint32(len(c.indexes)) {
 		return nil
+	} else if id < c.first {
+		return c.loadSpilled(id)
 	}
 
-	return c.indexes[id-1]
+	return c.indexes[id-c.first]
 }
 
 func (c *Cache) Clear() {
 	c.contents = make(map[string]UrlCache)
+	c.varies = make(map[string][]string)
+	c.merger = newRangeMerger()
 	c.urlIds = make(map[string][]uint32)
 	c.id = 0
 	c.indexes = make([]*UrlHistory, 0, 20)
-}
-
-func CheckRange(r *http.Request) string {
-	range_, ok := r.Header["Range"]
-	if ok && len(range_) > 0 {
-		return range_[0]
-	} else {
-		return ""
-	}
-}
-
-func MakeRange(rangeInfo string, content []byte) ([]byte, string, error) {
-	if !strings.HasPrefix(rangeInfo, "bytes=") {
-		return nil, "", fmt.Errorf("unknown range: %s", rangeInfo)
-	}
-
-	s := strings.Split(rangeInfo[len("bytes="):], "-")
-	if len(s) == 0 || len(s[0]) == 0 {
-		return nil, "", fmt.Errorf("range has no sep: %s", rangeInfo)
-	}
-
-	begin, err := strconv.ParseInt(s[0], 10, 64)
-	if err != nil {
-		return nil, "", fmt.Errorf("invalid range offset(%s), from %s", s[0], rangeInfo)
-	}
-
-	if len(s) == 1 || len(s[1]) == 0 {
-		if begin >= int64(len(content)) {
-			return nil, "", fmt.Errorf("out of range: %d, from %s", begin, rangeInfo)
-		} else {
-			contentRange := fmt.Sprintf("%d-%d/%d", begin, len(content)-1, len(content))
-			return content[begin:], contentRange, nil
-		}
-	}
-
-	end, err := strconv.ParseInt(s[1], 10, 64)
-	if err != nil {
-		return nil, "", fmt.Errorf("invalid range offset(%s), from %s", s[1], rangeInfo)
-	}
-
-	if end < begin {
-		return nil, "", fmt.Errorf("out of range: %d !<= %d, from %s", begin, end, rangeInfo)
-	} else if end >= int64(len(content)) {
-		return nil, "", fmt.Errorf("out of range: %d, from %s", end, rangeInfo)
-	} else {
-		contentRange := fmt.Sprintf("%d-%d/%d", begin, end, len(content))
-		return content[begin : end+1], contentRange, nil
-	}
+	c.first = 1
+	c.size = 0
+	c.usage = HistoryUsage{}
+	c.clearSpilled()
 }
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCacheVary(t *testing.T) {
+	content := func(key, lang, body string) *UrlCache {
+		req := http.Header{}
+		req.Set("Accept-Language", lang)
+		resp := http.Header{}
+		resp.Set("Vary", "accept-language, Accept-Encoding")
+		return &UrlCache{Url: "http://g.cn/a", Method: "GET", RequestHeader: req, Bytes: []byte(body), ResponseHeader: resp, ResponseCode: 200, Key: key}
+	}
+
+	header := func(lang string) http.Header {
+		h := http.Header{}
+		h.Set("Accept-Language", lang)
+		return h
+	}
+
+	c := NewCache()
+	c.Save(content("", "zh", "你好"), true)
+	c.Save(content("", "en", "hello"), true)
+	if u := c.Take("http://g.cn/a", "", header("zh")); u == nil || string(u.Bytes) != "你好" {
+		t.Errorf("Take(zh) wrong: %v", u)
+	}
+
+	if u := c.Take("http://g.cn/a", "bytes=0-1", header("en")); u == nil || string(u.Bytes) != "he" {
+		t.Errorf("Take(en, range) wrong: %v", u)
+	}
+
+	if u := c.Take("http://g.cn/a", "", header("fr")); u != nil {
+		t.Errorf("Take(fr) should be nil: %v", u)
+	}
+
+	c.Save(content("http://g.cn/a POST", "en", "posted"), true)
+	if u := c.Take("http://g.cn/a POST", "", header("en")); u == nil || string(u.Bytes) != "posted" {
+		t.Errorf("Take(key) wrong: %v", u)
+	} else if u := c.Take("http://g.cn/a", "", header("en")); u == nil || string(u.Bytes) != "hello" {
+		t.Errorf("Take(en) changed by other key: %v", u)
+	}
+
+	star := content("", "en", "star")
+	star.Url = "http://g.cn/star"
+	star.ResponseHeader.Set("Vary", "*")
+	c.Save(star, true)
+	if u := c.Take("http://g.cn/star", "", header("en")); u != nil {
+		t.Errorf("Take() of Vary * should be nil: %v", u)
+	}
+}
@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"github.com/benbearchen/asuran/util"
+
+	"crypto/md5"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SharedScope is the scope of disk cache for all profiles.
+const SharedScope = "shared"
+
+const diskCacheSuffix = ".gob"
+
+type diskHeader struct {
+	Key    string
+	Expire time.Time // zero for no expiry
+
+	// Base is the key without range and Vary, and Vary is header names
+	Base string
+	Vary []string
+}
+
+type diskEntry struct {
+	diskHeader
+	file   string
+	size   int64
+	access time.Time
+}
+
+// DiskCache keeps contents in files under a dir, which survive restart.
+// Files are evicted by least recent access when the total size exceeds.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+	size    int64
+	entries map[string]*diskEntry
+	varies  map[string][]string
+	merger  *rangeMerger
+
+	lock sync.Mutex
+}
+
+func NewDiskCache(dir string, maxSize int64) *DiskCache {
+	d := new(DiskCache)
+	d.dir = dir
+	d.maxSize = maxSize
+	d.entries = make(map[string]*diskEntry)
+	d.varies = make(map[string][]string)
+	d.merger = newRangeMerger()
+
+	util.MakeDir(dir)
+	d.load()
+
+	return d
+}
+
+func diskKey(scope, key, rangeInfo string) string {
+	return scope + " " + rangeInfo + " <> " + key
+}
+
+func diskBase(scope, key string) string {
+	return scope + " " + key
+}
+
+func (d *DiskCache) load() {
+	files, err := filepath.Glob(filepath.Join(d.dir, "*"+diskCacheSuffix))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+
+		h, err := readDiskHeader(file)
+		if err != nil || (!h.Expire.IsZero() && now.After(h.Expire)) {
+			os.Remove(file)
+			continue
+		}
+
+		d.entries[h.Key] = &diskEntry{*h, file, fi.Size(), fi.ModTime()}
+		d.varies[h.Base] = h.Vary
+		d.size += fi.Size()
+	}
+}
+
+func readDiskHeader(file string) (*diskHeader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	h := new(diskHeader)
+	err = gob.NewDecoder(f).Decode(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (d *DiskCache) SetMaxSize(maxSize int64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.maxSize = maxSize
+	d.evict()
+}
+
+// Save writes c to disk for scope, the profile ip or SharedScope.
+// ttl 0 means no expiry.
+func (d *DiskCache) Save(scope string, c *UrlCache, ttl time.Duration) error {
+	if c.Error != nil {
+		return fmt.Errorf("can't save error: %v", c.Error)
+	}
+
+	names, ok := varyNames(c.ResponseHeader)
+	if !ok {
+		return fmt.Errorf("can't save for Vary: *")
+	}
+
+	key := c.cacheKey()
+	suffix := varySuffix(names, c.keyHeader())
+	if full := d.merger.add(diskBase(scope, key)+suffix, c); full != nil {
+		d.Save(scope, full, ttl)
+	}
+
+	h := diskHeader{diskKey(scope, key, c.RangeInfo) + suffix, time.Time{}, diskBase(scope, key), names}
+	if ttl > 0 {
+		h.Expire = time.Now().Add(ttl)
+	}
+
+	file := filepath.Join(d.dir, fmt.Sprintf("%x", md5.Sum([]byte(h.Key)))+diskCacheSuffix)
+	f, err := os.CreateTemp(d.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	e := gob.NewEncoder(f)
+	err = e.Encode(&h)
+	if err == nil {
+		err = e.Encode(c)
+	}
+
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	fi, err := os.Stat(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if old, ok := d.entries[h.Key]; ok {
+		d.size -= old.size
+	}
+
+	d.entries[h.Key] = &diskEntry{h, file, fi.Size(), time.Now()}
+	d.varies[h.Base] = names
+	d.size += fi.Size()
+	d.evict()
+	return nil
+}
+
+// Take is like Cache.Take, ranges may be made from the whole content.
+func (d *DiskCache) Take(scope, key, rangeInfo string, header http.Header) *UrlCache {
+	c := d.read(scope, key, rangeInfo, header)
+	if c != nil && (len(rangeInfo) == 0 || MatchIfRange(header, c.ResponseHeader)) {
+		return c
+	} else if len(rangeInfo) == 0 {
+		return nil
+	}
+
+	return takeRange(d.read(scope, key, "", header), rangeInfo, header)
+}
+
+// read looks up and touches the entry under lock, but opens and decodes
+// the file out of it, so that a big content doesn't block others.
+func (d *DiskCache) read(scope, key, rangeInfo string, header http.Header) *UrlCache {
+	full, e, now := d.lookup(scope, key, rangeInfo, header)
+	if e == nil {
+		return nil
+	}
+
+	c, err := readDiskContent(e.file, full)
+	if err != nil {
+		d.lock.Lock()
+		if d.entries[full] == e {
+			d.remove(e)
+		}
+
+		d.lock.Unlock()
+		return nil
+	}
+
+	os.Chtimes(e.file, now, now)
+	return c
+}
+
+func (d *DiskCache) lookup(scope, key, rangeInfo string, header http.Header) (string, *diskEntry, time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	full := diskKey(scope, key, rangeInfo) + varySuffix(d.varies[diskBase(scope, key)], header)
+	e, ok := d.entries[full]
+	now := time.Now()
+	if !ok {
+		return full, nil, now
+	}
+
+	if !e.Expire.IsZero() && now.After(e.Expire) {
+		d.remove(e)
+		return full, nil, now
+	}
+
+	e.access = now
+	return full, e, now
+}
+
+func readDiskContent(file, key string) (*UrlCache, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	dec := gob.NewDecoder(f)
+	var h diskHeader
+	c := new(UrlCache)
+	if err := dec.Decode(&h); err != nil {
+		return nil, err
+	} else if h.Key != key {
+		return nil, fmt.Errorf("unmatch key: %s", h.Key)
+	} else if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Size returns the count and total bytes of entries.
+func (d *DiskCache) Size() (int, int64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return len(d.entries), d.size
+}
+
+func (d *DiskCache) remove(e *diskEntry) {
+	os.Remove(e.file)
+	delete(d.entries, e.Key)
+	d.size -= e.size
+}
+
+func (d *DiskCache) evict() {
+	if d.maxSize <= 0 || d.size <= d.maxSize {
+		return
+	}
+
+	entries := make([]*diskEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].access.Before(entries[j].access) })
+	for _, e := range entries {
+		if d.size <= d.maxSize {
+			break
+		}
+
+		d.remove(e)
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asuran-cache")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	content := func(url, body string) *UrlCache {
+		h := http.Header{}
+		h.Set("Content-Type", "text/plain")
+		return &UrlCache{Url: url, Method: "GET", Bytes: []byte(body), ResponseHeader: h, ResponseCode: 200}
+	}
+
+	d := NewDiskCache(dir, 0)
+	if err := d.Save("1.2.3.4", content("http://g.cn/a", "0123456789"), 0); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	d.Save(SharedScope, content("http://g.cn/b", "shared"), 0)
+	d.Save("1.2.3.4", content("http://g.cn/c", "expired"), time.Millisecond)
+
+	if d.Take("1.2.3.5", "http://g.cn/a", "", nil) != nil {
+		t.Errorf("Take() of other scope should be nil")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	d = NewDiskCache(dir, 0)
+	if c := d.Take("1.2.3.4", "http://g.cn/a", "", nil); c == nil || string(c.Bytes) != "0123456789" || c.ResponseHeader.Get("Content-Type") != "text/plain" {
+		t.Errorf("Take() after reload wrong: %v", c)
+	}
+
+	if c := d.Take("1.2.3.4", "http://g.cn/a", "bytes=2-4", nil); c == nil || string(c.Bytes) != "234" || c.ResponseCode != 206 {
+		t.Errorf("Take() range wrong: %v", c)
+	}
+
+	if c := d.Take(SharedScope, "http://g.cn/b", "", nil); c == nil || string(c.Bytes) != "shared" {
+		t.Errorf("Take() shared wrong: %v", c)
+	}
+
+	if c := d.Take("1.2.3.4", "http://g.cn/c", "", nil); c != nil {
+		t.Errorf("Take() expired should be nil: %v", c)
+	}
+
+	if n, _ := d.Size(); n != 2 {
+		t.Errorf("Size() count %d, should be 2", n)
+	}
+
+	// take a again, so b is the least recent one
+	d.Take("1.2.3.4", "http://g.cn/a", "", nil)
+	_, size := d.Size()
+	d.SetMaxSize(size - 1)
+	if d.Take(SharedScope, "http://g.cn/b", "", nil) != nil || d.Take("1.2.3.4", "http://g.cn/a", "", nil) == nil {
+		t.Errorf("SetMaxSize() should evict the least recent one")
+	}
+}
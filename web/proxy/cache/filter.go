@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type statusRange struct {
+	min, max int
+}
+
+// HistoryFilter selects histories, zero values match all.
+type HistoryFilter struct {
+	Url         string // substring of url
+	UrlRegexp   *regexp.Regexp
+	Method      string
+	Status      []statusRange // any of them
+	MinDuration time.Duration
+	MaxDuration time.Duration
+	ContentType string // substring of response Content-Type, ignoring case
+	Policy      string // substring of the url policy applied
+	ErrorOnly   bool
+	Since       time.Time
+	Until       time.Time
+}
+
+// ParseHistoryFilter reads the filter from params:
+//
+//	url=<substring>  regexp=<url-regexp>  method=GET
+//	status=200,300-399,5xx  min=1s  max=500ms
+//	type=json  policy=<substring>  error=1
+//	since=<time>  until=<time>, of RFC 3339 or unix seconds
+func ParseHistoryFilter(v url.Values) (*HistoryFilter, error) {
+	f := &HistoryFilter{}
+	f.Url = v.Get("url")
+	f.Method = strings.ToUpper(v.Get("method"))
+	f.ContentType = strings.ToLower(v.Get("type"))
+	f.Policy = v.Get("policy")
+
+	if s := v.Get("regexp"); len(s) > 0 {
+		r, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("wrong regexp: %v", err)
+		}
+
+		f.UrlRegexp = r
+	}
+
+	if s := v.Get("status"); len(s) > 0 {
+		status, err := parseStatusRanges(s)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Status = status
+	}
+
+	for _, d := range []struct {
+		name string
+		d    *time.Duration
+	}{{"min", &f.MinDuration}, {"max", &f.MaxDuration}} {
+		if s := v.Get(d.name); len(s) > 0 {
+			dv, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("wrong %s: %s", d.name, s)
+			}
+
+			*d.d = dv
+		}
+	}
+
+	switch s := v.Get("error"); s {
+	case "", "0", "false":
+	default:
+		f.ErrorOnly = true
+	}
+
+	for _, t := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if s := v.Get(t.name); len(s) > 0 {
+			tv, err := ParseFilterTime(s)
+			if err != nil {
+				return nil, fmt.Errorf("wrong %s: %s", t.name, s)
+			}
+
+			*t.t = tv
+		}
+	}
+
+	return f, nil
+}
+
+// ParseFilterTime parses v of RFC 3339 or unix seconds.
+func ParseFilterTime(v string) (time.Time, error) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+
+	return time.Parse(time.RFC3339, v)
+}
+
+// parseStatusRanges parses s like "200,300-399,5xx".
+func parseStatusRanges(s string) ([]statusRange, error) {
+	ranges := make([]statusRange, 0)
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if len(r) == 0 {
+			continue
+		}
+
+		var min, max int
+		var err error
+		if len(r) == 3 && strings.HasSuffix(strings.ToLower(r), "xx") && r[0] >= '1' && r[0] <= '9' {
+			min = int(r[0]-'0') * 100
+			max = min + 99
+		} else if d := strings.IndexByte(r, '-'); d > 0 {
+			min, err = strconv.Atoi(r[:d])
+			if err == nil {
+				max, err = strconv.Atoi(r[d+1:])
+			}
+		} else {
+			min, err = strconv.Atoi(r)
+			max = min
+		}
+
+		if err != nil || min > max {
+			return nil, fmt.Errorf("wrong status: %s", r)
+		}
+
+		ranges = append(ranges, statusRange{min, max})
+	}
+
+	return ranges, nil
+}
+
+func (f *HistoryFilter) Match(h *UrlHistory) bool {
+	if len(f.Url) > 0 && !strings.Contains(h.Url, f.Url) {
+		return false
+	} else if f.UrlRegexp != nil && !f.UrlRegexp.MatchString(h.Url) {
+		return false
+	} else if len(f.Method) > 0 && h.Method != f.Method {
+		return false
+	} else if len(f.Status) > 0 && !f.matchStatus(h.ResponseCode) {
+		return false
+	} else if f.MinDuration > 0 && h.Duration < f.MinDuration {
+		return false
+	} else if f.MaxDuration > 0 && h.Duration > f.MaxDuration {
+		return false
+	} else if len(f.ContentType) > 0 && !strings.Contains(strings.ToLower(h.ResponseHeader.Get("Content-Type")), f.ContentType) {
+		return false
+	} else if len(f.Policy) > 0 && !strings.Contains(h.Policy, f.Policy) {
+		return false
+	} else if f.ErrorOnly && h.Error == nil {
+		return false
+	} else if !f.Since.IsZero() && h.Time.Before(f.Since) {
+		return false
+	} else if !f.Until.IsZero() && h.Time.After(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+func (f *HistoryFilter) matchStatus(status int) bool {
+	for _, r := range f.Status {
+		if r.min <= status && status <= r.max {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter returns histories which match f, in the same order.
+func (f *HistoryFilter) Filter(histories []*UrlHistory) []*UrlHistory {
+	r := make([]*UrlHistory, 0)
+	for _, h := range histories {
+		if f == nil || f.Match(h) {
+			r = append(r, h)
+		}
+	}
+
+	return r
+}
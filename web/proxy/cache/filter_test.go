@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHistoryFilter(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	histories := make([]*UrlHistory, 0)
+	for i, s := range []struct {
+		method, url string
+		status      int
+		duration    time.Duration
+		contentType string
+		policy      string
+		err         error
+	}{
+		{"GET", "http://a.cn/1.json", 200, 10 * time.Millisecond, "application/json", "", nil},
+		{"POST", "http://b.cn/2", 302, 2 * time.Second, "text/html", "url delay 2s b.cn/", nil},
+		{"GET", "http://a.cn/3", 404, time.Second, "text/plain", "url status 404 a.cn/3", nil},
+		{"GET", "http://c.cn/4", 0, 5 * time.Second, "", "", fmt.Errorf("timeout")},
+	} {
+		c := UrlCache{Time: start.Add(time.Duration(i) * time.Minute), Url: s.url, Method: s.method, ResponseCode: s.status, Duration: s.duration, Policy: s.policy, Error: s.err}
+		c.ResponseHeader = make(http.Header)
+		if len(s.contentType) > 0 {
+			c.ResponseHeader.Set("Content-Type", s.contentType)
+		}
+
+		histories = append(histories, &UrlHistory{UrlCache: c, ID: uint32(i + 1)})
+	}
+
+	for query, want := range map[string]string{
+		"":                           "1234",
+		"url=a.cn":                   "13",
+		"regexp=%5C.json%24":         "1",
+		"method=post":                "2",
+		"status=2xx,400-499":         "13",
+		"status=302":                 "2",
+		"min=1s":                     "234",
+		"min=1s&max=2s":              "23",
+		"type=JSON":                  "1",
+		"policy=delay":               "2",
+		"error=1":                    "4",
+		"since=1500000060":           "234",
+		"until=2017-07-14T02:40:30Z": "1",
+		"until=1500000060&url=a.cn":  "1",
+	} {
+		v, _ := url.ParseQuery(query)
+		f, err := ParseHistoryFilter(v)
+		if err != nil {
+			t.Errorf("ParseHistoryFilter(%s) failed: %v", query, err)
+			continue
+		}
+
+		ids := ""
+		for _, h := range f.Filter(histories) {
+			ids += fmt.Sprint(h.ID)
+		}
+
+		if ids != want {
+			t.Errorf("filter %s: %s, want %s", query, ids, want)
+		}
+	}
+
+	for _, query := range []string{"regexp=(", "status=abc", "status=500-400", "min=1", "since=yesterday"} {
+		v, _ := url.ParseQuery(query)
+		if _, err := ParseHistoryFilter(v); err == nil {
+			t.Errorf("ParseHistoryFilter(%s) should fail", query)
+		}
+	}
+}
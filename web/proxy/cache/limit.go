@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// HistoryLimit caps histories in memory, 0 means no limit. The oldest ones
+// beyond are spilled to disk, or dropped if Evict or spilling is disabled.
+type HistoryLimit struct {
+	Count int
+	Size  int64
+	Evict bool
+}
+
+type HistoryUsage struct {
+	Count       int
+	Size        int64
+	Spilled     int
+	SpilledSize int64
+	Evicted     int
+}
+
+// Omitted returns the count of histories out of memory, which are not
+// listed by Cache.Histories.
+func (u HistoryUsage) Omitted() int {
+	return u.Spilled + u.Evicted
+}
+
+// spilledHistory is the history on disk, whose error can't be encoded.
+type spilledHistory struct {
+	History UrlHistory
+	Error   string
+}
+
+func historySize(h *UrlHistory) int64 {
+	size := len(h.Url) + len(h.PostBody) + len(h.OriginalPostBody) + len(h.Bytes)
+	for _, header := range []http.Header{h.RequestHeader, h.ResponseHeader} {
+		for k, vs := range header {
+			for _, v := range vs {
+				size += len(k) + len(v)
+			}
+		}
+	}
+
+	return int64(size)
+}
+
+// SetSpillDir enables spilling to dir, which is cleared for histories of
+// the last run.
+func (c *Cache) SetSpillDir(dir string) {
+	c.spillDir = dir
+	c.clearSpilled()
+}
+
+func (c *Cache) clearSpilled() {
+	if len(c.spillDir) > 0 {
+		os.RemoveAll(c.spillDir)
+	}
+}
+
+func (c *Cache) spillFile(id uint32) string {
+	return filepath.Join(c.spillDir, strconv.FormatUint(uint64(id), 10)+".gob")
+}
+
+// Limit drops the oldest histories in memory until they are within l.
+func (c *Cache) Limit(l HistoryLimit) {
+	count, size := len(c.indexes), c.size
+	if l.Count > 0 && l.Count < count {
+		count = l.Count
+	}
+
+	if l.Size > 0 && l.Size < size {
+		size = l.Size
+	}
+
+	c.Shrink(count, size, l.Evict)
+}
+
+// Shrink is like Limit, but count and size of 0 drop all histories in
+// memory.
+func (c *Cache) Shrink(count int, size int64, evict bool) {
+	for len(c.indexes) > 0 && (len(c.indexes) > count || c.size > size) {
+		h := c.indexes[0]
+		c.indexes[0] = nil
+		c.indexes = c.indexes[1:]
+		c.first++
+
+		size := historySize(h)
+		c.size -= size
+		if !evict && len(c.spillDir) > 0 {
+			if err := c.spill(h); err == nil {
+				c.usage.Spilled++
+				c.usage.SpilledSize += size
+				continue
+			}
+		}
+
+		c.usage.Evicted++
+		c.removeUrlId(h.Url, h.ID)
+	}
+}
+
+func (c *Cache) spill(h *UrlHistory) error {
+	if err := os.MkdirAll(c.spillDir, 0755); err != nil {
+		return err
+	}
+
+	s := spilledHistory{History: *h}
+	s.History.Error = nil
+	if h.Error != nil {
+		s.Error = h.Error.Error()
+	}
+
+	file := c.spillFile(h.ID)
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(f).Encode(&s)
+	f.Close()
+	if err != nil {
+		os.Remove(file)
+	}
+
+	return err
+}
+
+func (c *Cache) loadSpilled(id uint32) *UrlHistory {
+	if len(c.spillDir) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(c.spillFile(id))
+	if err != nil {
+		return nil
+	}
+
+	defer f.Close()
+	var s spilledHistory
+	if err := gob.NewDecoder(f).Decode(&s); err != nil {
+		return nil
+	}
+
+	if len(s.Error) > 0 {
+		s.History.Error = errors.New(s.Error)
+	}
+
+	return &s.History
+}
+
+// FindSpilled is like Find, but looks up histories of url on disk only.
+func (c *Cache) FindSpilled(url string, score func(h *UrlHistory) int) *UrlHistory {
+	var found *UrlHistory
+	best := 0
+	ids := c.urlIds[url]
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i] >= c.first {
+			continue
+		}
+
+		if h := c.loadSpilled(ids[i]); h != nil {
+			if s := score(h); s > best {
+				found, best = h, s
+			}
+		}
+	}
+
+	return found
+}
+
+func (c *Cache) removeUrlId(url string, id uint32) {
+	ids := c.urlIds[url]
+	for i, v := range ids {
+		if v == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+
+	if len(ids) == 0 {
+		delete(c.urlIds, url)
+	} else {
+		c.urlIds[url] = ids
+	}
+}
+
+func (c *Cache) Usage() HistoryUsage {
+	u := c.usage
+	u.Count = len(c.indexes)
+	u.Size = c.size
+	return u
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHistoryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	c := NewCache()
+	c.SetSpillDir(dir)
+	for i := 1; i <= 5; i++ {
+		u := &UrlCache{Url: fmt.Sprintf("http://a.cn/%d", i%2), Method: "GET", Bytes: []byte(strings.Repeat("x", 100)), ResponseCode: 200}
+		if i == 2 {
+			u.Error = fmt.Errorf("timeout")
+		}
+
+		c.Save(u, false)
+		c.Limit(HistoryLimit{Count: 3})
+	}
+
+	if u := c.Usage(); u.Count != 3 || u.Spilled != 2 || u.Evicted != 0 || u.Size <= 300 {
+		t.Errorf("usage wrong: %+v", u)
+	}
+
+	if h := c.History(2); h == nil || h.ID != 2 || h.Url != "http://a.cn/0" || h.Error == nil || h.Error.Error() != "timeout" {
+		t.Errorf("spilled history wrong: %+v", h)
+	}
+
+	if h := c.History(5); h == nil || h.ID != 5 {
+		t.Errorf("history in memory wrong: %+v", h)
+	}
+
+	if h := c.History(6); h != nil {
+		t.Errorf("history 6 shouldn't exist: %+v", h)
+	}
+
+	if l := c.List("http://a.cn/1"); len(l) != 3 || l[0].ID != 1 || l[2].ID != 5 {
+		t.Errorf("List() wrong: %d", len(l))
+	}
+
+	if len(c.Histories()) != 3 || c.Usage().Omitted() != 2 {
+		t.Errorf("Histories() should be in memory only")
+	}
+
+	score := func(h *UrlHistory) int {
+		if h.Error == nil {
+			return 1
+		}
+
+		return 0
+	}
+
+	if h := c.FindSpilled("http://a.cn/1", score); h == nil || h.ID != 1 {
+		t.Errorf("FindSpilled() wrong: %+v", h)
+	} else if h := c.FindSpilled("http://a.cn/0", score); h != nil {
+		t.Errorf("FindSpilled() should skip histories in memory: %+v", h)
+	}
+
+	c.Limit(HistoryLimit{Size: 150, Evict: true})
+	if u := c.Usage(); u.Count != 1 || u.Evicted != 2 {
+		t.Errorf("usage after evict wrong: %+v", u)
+	}
+
+	if h := c.History(3); h != nil {
+		t.Errorf("evicted history 3 shouldn't exist")
+	}
+
+	if l := c.List("http://a.cn/1"); len(l) != 2 {
+		t.Errorf("List() after evict wrong: %d", len(l))
+	}
+
+	c.Save(&UrlCache{Url: "http://a.cn/6", Method: "GET", ResponseCode: 200}, false)
+	c.Shrink(0, 0, false)
+	if u := c.Usage(); u.Count != 0 || u.Size != 0 || u.Spilled != 4 {
+		t.Errorf("usage after shrink wrong: %+v", u)
+	}
+
+	c.Clear()
+	if h := c.History(1); h != nil {
+		t.Errorf("spilled history should be cleared")
+	} else if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("spill dir should be removed: %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMergeSize limits contents merged from ranges, which are in memory.
+const maxMergeSize = 64 * 1024 * 1024
+
+// byteRange is [start, end] of content.
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+func CheckRange(r *http.Request) string {
+	range_, ok := r.Header["Range"]
+	if ok && len(range_) > 0 {
+		return range_[0]
+	} else {
+		return ""
+	}
+}
+
+// parseRanges parses rangeInfo of RFC 7233 for content of size, ranges
+// out of size are dropped, and it fails if no range is left.
+func parseRanges(rangeInfo string, size int64) ([]byteRange, error) {
+	if !strings.HasPrefix(rangeInfo, "bytes=") {
+		return nil, fmt.Errorf("unknown range: %s", rangeInfo)
+	}
+
+	ranges := make([]byteRange, 0)
+	for _, spec := range strings.Split(rangeInfo[len("bytes="):], ",") {
+		spec = strings.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+
+		d := strings.IndexByte(spec, '-')
+		if d < 0 {
+			return nil, fmt.Errorf("range has no sep: %s", rangeInfo)
+		}
+
+		first, last := strings.TrimSpace(spec[:d]), strings.TrimSpace(spec[d+1:])
+		if len(first) == 0 {
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid suffix range(%s), from %s", spec, rangeInfo)
+			} else if n == 0 || size == 0 {
+				continue
+			} else if n > size {
+				n = size
+			}
+
+			ranges = append(ranges, byteRange{size - n, size - 1})
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid range offset(%s), from %s", first, rangeInfo)
+		}
+
+		end := size - 1
+		if len(last) > 0 {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range offset(%s), from %s", last, rangeInfo)
+			} else if end < start {
+				return nil, fmt.Errorf("out of range: %d !<= %d, from %s", start, end, rangeInfo)
+			} else if end >= size {
+				end = size - 1
+			}
+		}
+
+		if start >= size {
+			continue
+		}
+
+		ranges = append(ranges, byteRange{start, end})
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("out of range: %s, size %d", rangeInfo, size)
+	}
+
+	return ranges, nil
+}
+
+// MakeRange makes the body of rangeInfo from the whole content, and sets
+// Content-Range, Content-Length and Content-Type (for multipart/byteranges)
+// of header. On failure, header has Content-Range for 416.
+func MakeRange(rangeInfo string, content []byte, header http.Header) ([]byte, error) {
+	size := int64(len(content))
+	ranges, err := parseRanges(rangeInfo, size)
+	if err != nil {
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return nil, err
+	}
+
+	if len(ranges) == 1 {
+		r := ranges[0]
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size))
+		header.Set("Content-Length", strconv.FormatInt(r.end-r.start+1, 10))
+		return content[r.start : r.end+1], nil
+	}
+
+	var b bytes.Buffer
+	m := multipart.NewWriter(&b)
+	contentType := header.Get("Content-Type")
+	for _, r := range ranges {
+		h := make(textproto.MIMEHeader)
+		if len(contentType) > 0 {
+			h.Set("Content-Type", contentType)
+		}
+
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size))
+		w, err := m.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+
+		w.Write(content[r.start : r.end+1])
+	}
+
+	m.Close()
+	header.Del("Content-Range")
+	header.Set("Content-Type", "multipart/byteranges; boundary="+m.Boundary())
+	header.Set("Content-Length", strconv.Itoa(b.Len()))
+	return b.Bytes(), nil
+}
+
+// MatchIfRange tells whether If-Range of request matches the response,
+// which is true without If-Range. ETag is compared strongly, and date
+// should be exactly Last-Modified.
+func MatchIfRange(req, resp http.Header) bool {
+	ifRange := strings.TrimSpace(req.Get("If-Range"))
+	if len(ifRange) == 0 {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := resp.Get("ETag")
+		return !strings.HasPrefix(ifRange, "W/") && !strings.HasPrefix(etag, "W/") && etag == ifRange
+	}
+
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+
+	lm, err := http.ParseTime(resp.Get("Last-Modified"))
+	return err == nil && t.Equal(lm)
+}
+
+// takeRange makes the range of rangeInfo from uc of the whole content, or
+// returns uc itself if If-Range of header doesn't match.
+func takeRange(uc *UrlCache, rangeInfo string, header http.Header) *UrlCache {
+	if uc == nil || uc.Error != nil {
+		return nil
+	} else if !MatchIfRange(header, uc.ResponseHeader) {
+		return uc
+	}
+
+	fc, err := uc.Content()
+	if err != nil {
+		return nil
+	}
+
+	rc := *uc
+	rc.ResponseHeader = make(http.Header)
+	for k, vv := range uc.ResponseHeader {
+		for _, v := range vv {
+			rc.ResponseHeader.Add(k, v)
+		}
+	}
+
+	// ranges are of the decoded content
+	rc.ResponseHeader.Del("Content-Encoding")
+	cc, err := MakeRange(rangeInfo, fc, rc.ResponseHeader)
+	if err != nil {
+		return nil
+	}
+
+	rc.Bytes = cc
+	rc.ResponseCode = 206
+	rc.RangeInfo = rangeInfo
+	return &rc
+}
+
+// Range is like takeRange, but c is returned as is if it's of rangeInfo,
+// and nil if c isn't the whole content.
+func (c *UrlCache) Range(rangeInfo string, header http.Header) *UrlCache {
+	if c.RangeInfo == rangeInfo {
+		return c
+	} else if len(c.RangeInfo) > 0 || c.ResponseCode != 200 {
+		return nil
+	}
+
+	return takeRange(c, rangeInfo, header)
+}
+
+// parseContentRange parses "bytes <start>-<end>/<total>", total is -1
+// for "*".
+func parseContentRange(s string) (start, end, total int64, ok bool) {
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, false
+	}
+
+	s = s[len("bytes "):]
+	slash := strings.IndexByte(s, '/')
+	dash := strings.IndexByte(s, '-')
+	if slash < 0 || dash < 0 || dash > slash {
+		return 0, 0, 0, false
+	}
+
+	var err error
+	if start, err = strconv.ParseInt(s[:dash], 10, 64); err != nil {
+		return 0, 0, 0, false
+	} else if end, err = strconv.ParseInt(s[dash+1:slash], 10, 64); err != nil || end < start {
+		return 0, 0, 0, false
+	}
+
+	if s[slash+1:] == "*" {
+		return start, end, -1, true
+	} else if total, err = strconv.ParseInt(s[slash+1:], 10, 64); err != nil || end >= total {
+		return 0, 0, 0, false
+	}
+
+	return start, end, total, true
+}
+
+const (
+	// maxMergePartials and maxMergeBytes limit partial contents kept by a
+	// rangeMerger, the least recently added are dropped beyond.
+	maxMergePartials = 64
+	maxMergeBytes    = 256 * 1024 * 1024
+
+	// mergeTimeout drops partial contents not added to for a while.
+	mergeTimeout = 10 * time.Minute
+)
+
+type byteSegment struct {
+	start int64
+	data  []byte
+}
+
+type partialContent struct {
+	validator string
+	total     int64
+	segments  []byteSegment
+	size      int64       // bytes of segments
+	have      []byteRange // sorted and merged
+	access    time.Time
+}
+
+// covers tells whether r is in ranges already got.
+func (p *partialContent) covers(r byteRange) bool {
+	for _, h := range p.have {
+		if h.start <= r.start && r.end <= h.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rangeMerger merges 206 responses of the same content, until the whole
+// content is fetched. Only received segments are kept, and the whole
+// content is made at last.
+type rangeMerger struct {
+	partials map[string]*partialContent
+	size     int64
+
+	lock sync.Mutex
+}
+
+func newRangeMerger() *rangeMerger {
+	return &rangeMerger{partials: make(map[string]*partialContent)}
+}
+
+// add returns the whole content once c completes it, or nil.
+func (m *rangeMerger) add(key string, c *UrlCache) *UrlCache {
+	if c.ResponseCode != 206 || c.Error != nil {
+		return nil
+	}
+
+	start, end, total, ok := parseContentRange(c.ResponseHeader.Get("Content-Range"))
+	if !ok || total <= 0 || total > maxMergeSize || end-start+1 != int64(len(c.Bytes)) {
+		return nil
+	}
+
+	validator := c.ResponseHeader.Get("ETag")
+	if len(validator) == 0 {
+		validator = c.ResponseHeader.Get("Last-Modified")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	p, ok := m.partials[key]
+	if !ok || p.validator != validator || p.total != total {
+		m.remove(key)
+		p = &partialContent{validator: validator, total: total}
+		m.partials[key] = p
+	}
+
+	p.access = now
+	r := byteRange{start, end}
+	if !p.covers(r) {
+		p.segments = append(p.segments, byteSegment{start, c.Bytes})
+		p.size += int64(len(c.Bytes))
+		m.size += int64(len(c.Bytes))
+		p.have = mergeByteRange(p.have, r)
+	}
+
+	if len(p.have) != 1 || p.have[0].start != 0 || p.have[0].end != total-1 {
+		m.evict(now)
+		return nil
+	}
+
+	m.remove(key)
+	data := make([]byte, total)
+	for _, seg := range p.segments {
+		copy(data[seg.start:], seg.data)
+	}
+
+	full := *c
+	full.Bytes = data
+	full.RangeInfo = ""
+	full.ResponseCode = 200
+	full.ResponseHeader = make(http.Header)
+	for k, vv := range c.ResponseHeader {
+		for _, v := range vv {
+			full.ResponseHeader.Add(k, v)
+		}
+	}
+
+	full.ResponseHeader.Del("Content-Range")
+	full.ResponseHeader.Set("Content-Length", strconv.FormatInt(total, 10))
+	return &full
+}
+
+func (m *rangeMerger) remove(key string) {
+	if p, ok := m.partials[key]; ok {
+		m.size -= p.size
+		delete(m.partials, key)
+	}
+}
+
+// evict drops stale partials, then the least recently added ones until
+// the limits are met.
+func (m *rangeMerger) evict(now time.Time) {
+	for key, p := range m.partials {
+		if now.Sub(p.access) > mergeTimeout {
+			m.remove(key)
+		}
+	}
+
+	for len(m.partials) > maxMergePartials || m.size > maxMergeBytes {
+		oldest := ""
+		var access time.Time
+		for key, p := range m.partials {
+			if len(oldest) == 0 || p.access.Before(access) {
+				oldest, access = key, p.access
+			}
+		}
+
+		m.remove(oldest)
+	}
+}
+
+// stat returns the count and bytes of partial contents.
+func (m *rangeMerger) stat() (int, int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.partials), m.size
+}
+
+func mergeByteRange(ranges []byteRange, r byteRange) []byteRange {
+	ranges = append(ranges, r)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end+1 {
+			if r.end > last.end {
+				last.end = r.end
+			}
+		} else {
+			merged = append(merged, r)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMakeRange(t *testing.T) {
+	content := []byte("0123456789")
+	check := func(rangeInfo, body, contentRange string) {
+		h := http.Header{}
+		b, err := MakeRange(rangeInfo, content, h)
+		if err != nil {
+			t.Errorf("MakeRange(%s) failed: %v", rangeInfo, err)
+		} else if string(b) != body || h.Get("Content-Range") != contentRange || h.Get("Content-Length") != "" && h.Get("Content-Length") != strconv.Itoa(len(b)) {
+			t.Errorf("MakeRange(%s) wrong: %q, %v", rangeInfo, b, h)
+		}
+	}
+
+	check("bytes=2-4", "234", "bytes 2-4/10")
+	check("bytes=7-", "789", "bytes 7-9/10")
+	check("bytes=-3", "789", "bytes 7-9/10")
+	check("bytes=-30", "0123456789", "bytes 0-9/10")
+	check("bytes=8-20", "89", "bytes 8-9/10")
+	check("bytes=20-30, 1-1", "1", "bytes 1-1/10")
+
+	for _, rangeInfo := range []string{"bytes=10-", "bytes=5-2", "bytes=a-b", "items=0-1", "bytes=-0"} {
+		h := http.Header{}
+		if _, err := MakeRange(rangeInfo, content, h); err == nil {
+			t.Errorf("MakeRange(%s) should fail", rangeInfo)
+		} else if h.Get("Content-Range") != "bytes */10" {
+			t.Errorf("MakeRange(%s) Content-Range wrong: %v", rangeInfo, h)
+		}
+	}
+
+	h := http.Header{}
+	h.Set("Content-Type", "text/plain")
+	b, err := MakeRange("bytes=0-1,5-9", content, h)
+	if err != nil {
+		t.Fatalf("MakeRange(multi) failed: %v", err)
+	}
+
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mt != "multipart/byteranges" || h.Get("Content-Range") != "" {
+		t.Fatalf("MakeRange(multi) header wrong: %v", h)
+	}
+
+	r := multipart.NewReader(strings.NewReader(string(b)), params["boundary"])
+	parts := []string{"01", "56789"}
+	ranges := []string{"bytes 0-1/10", "bytes 5-9/10"}
+	for i := 0; ; i++ {
+		p, err := r.NextPart()
+		if err != nil {
+			if i != len(parts) {
+				t.Errorf("MakeRange(multi) has %d parts: %v", i, err)
+			}
+
+			break
+		}
+
+		d, _ := ioutil.ReadAll(p)
+		if i >= len(parts) || string(d) != parts[i] || p.Header.Get("Content-Range") != ranges[i] || p.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("MakeRange(multi) part %d wrong: %q, %v", i, d, p.Header)
+		}
+	}
+}
+
+func TestMatchIfRange(t *testing.T) {
+	resp := http.Header{}
+	resp.Set("ETag", `"v1"`)
+	resp.Set("Last-Modified", "Mon, 19 Oct 2026 08:00:00 GMT")
+	check := func(ifRange string, match bool) {
+		req := http.Header{}
+		if len(ifRange) > 0 {
+			req.Set("If-Range", ifRange)
+		}
+
+		if MatchIfRange(req, resp) != match {
+			t.Errorf("MatchIfRange(%s) should be %v", ifRange, match)
+		}
+	}
+
+	check("", true)
+	check(`"v1"`, true)
+	check(`"v2"`, false)
+	check(`W/"v1"`, false)
+	check("Mon, 19 Oct 2026 08:00:00 GMT", true)
+	check("Mon, 19 Oct 2026 08:00:01 GMT", false)
+}
+
+func TestCacheRangeMerge(t *testing.T) {
+	partial := func(rangeInfo, contentRange, body string) *UrlCache {
+		h := http.Header{}
+		h.Set("Content-Range", contentRange)
+		h.Set("ETag", `"v1"`)
+		return &UrlCache{Url: "http://g.cn/v", Method: "GET", Bytes: []byte(body), ResponseHeader: h, ResponseCode: 206, RangeInfo: rangeInfo}
+	}
+
+	c := NewCache()
+	c.Save(partial("bytes=0-3", "bytes 0-3/10", "0123"), true)
+	if u := c.Take("http://g.cn/v", "", nil); u != nil {
+		t.Errorf("Take() should be nil before merged: %v", u)
+	}
+
+	c.Save(partial("bytes=6-", "bytes 6-9/10", "6789"), true)
+	c.Save(partial("bytes=3-6", "bytes 3-6/10", "3456"), true)
+	u := c.Take("http://g.cn/v", "", nil)
+	if u == nil || string(u.Bytes) != "0123456789" || u.ResponseCode != 200 || u.ResponseHeader.Get("Content-Range") != "" || u.ResponseHeader.Get("Content-Length") != "10" {
+		t.Fatalf("Take() merged wrong: %v", u)
+	}
+
+	if u := c.Take("http://g.cn/v", "bytes=-2", nil); u == nil || string(u.Bytes) != "89" || u.ResponseCode != 206 {
+		t.Errorf("Take(suffix) wrong: %v", u)
+	}
+
+	h := http.Header{}
+	h.Set("If-Range", `"v0"`)
+	if u := c.Take("http://g.cn/v", "bytes=-2", h); u == nil || u.ResponseCode != 200 || len(u.Bytes) != 10 {
+		t.Errorf("Take(If-Range unmatched) should be whole: %v", u)
+	}
+}
+
+func TestRangeMergerLimit(t *testing.T) {
+	partial := func(contentRange, body string) *UrlCache {
+		h := http.Header{}
+		h.Set("Content-Range", contentRange)
+		return &UrlCache{Method: "GET", Bytes: []byte(body), ResponseHeader: h, ResponseCode: 206}
+	}
+
+	m := newRangeMerger()
+	m.add("a", partial("bytes 0-3/10", "0123"))
+	m.add("a", partial("bytes 1-2/10", "12"))
+	if n, size := m.stat(); n != 1 || size != 4 {
+		t.Errorf("covered segment should be dropped: %d, %d", n, size)
+	}
+
+	for i := 0; i < maxMergePartials+2; i++ {
+		m.add(fmt.Sprintf("k%d", i), partial("bytes 0-1/10", "01"))
+	}
+
+	if n, _ := m.stat(); n != maxMergePartials {
+		t.Errorf("partials not limited: %d", n)
+	} else if _, ok := m.partials["a"]; ok {
+		t.Errorf("oldest partial not evicted")
+	}
+
+	for _, p := range m.partials {
+		p.access = p.access.Add(-mergeTimeout - time.Second)
+	}
+
+	m.add("b", partial("bytes 0-1/10", "01"))
+	if n, size := m.stat(); n != 1 || size != 2 {
+		t.Errorf("stale partials not evicted: %d, %d", n, size)
+	}
+
+	if full := m.add("b", partial("bytes 2-9/10", "23456789")); full == nil || string(full.Bytes) != "0123456789" {
+		t.Errorf("merge wrong: %v", full)
+	} else if n, size := m.stat(); n != 0 || size != 0 {
+		t.Errorf("merged partial not removed: %d, %d", n, size)
+	}
+}
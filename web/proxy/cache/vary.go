@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// varyNames returns header names of Vary in h, and false for "*", which
+// means the response should never be cached.
+func varyNames(h http.Header) ([]string, bool) {
+	names := make([]string, 0)
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			} else if len(name) > 0 {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names, true
+}
+
+// varySuffix tells requests apart by values of names in h.
+func varySuffix(names []string, h http.Header) string {
+	s := ""
+	for _, name := range names {
+		s += " | " + name + "=" + strings.Join(h.Values(name), ",")
+	}
+
+	return s
+}
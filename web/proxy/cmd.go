@@ -45,6 +45,18 @@ func (p *Proxy) Command(commands string, f *profile.Profile, v *life.Life) []str
 		case *policy.PluginPolicy:
 			context := &policy.PluginContext{f.Ip, "", nil}
 			f.SetPluginPolicy(p, context, &pluginOperator{})
+		case *policy.NetworkPolicy:
+			f.SetNetworkPolicy(p)
+		case *policy.BandwidthPolicy:
+			f.SetBandwidthPolicy(p)
+		case *policy.OfflinePolicy:
+			f.SetOfflinePolicy(p)
+		case *policy.RecordPolicy:
+			f.SetRecordPolicy(p)
+		case *policy.ReplayPolicy:
+			f.SetReplayPolicy(p)
+		case *policy.HistoryPolicy:
+			f.SetHistoryPolicy(p)
 		default:
 		}
 	}
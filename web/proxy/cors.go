@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/policy"
+
+	"net/http"
+	"sort"
+	"strings"
+)
+
+func isCorsPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && len(r.Header.Get("Origin")) > 0 && len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// corsPreflight answers the preflight request locally.
+func corsPreflight(w http.ResponseWriter, r *http.Request, cp *policy.CorsPolicy) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if cp.AllowOrigin(origin) {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Allow-Methods", "GET, POST, HEAD, PUT, PATCH, DELETE, OPTIONS")
+		if headers := r.Header.Get("Access-Control-Request-Headers"); len(headers) > 0 {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+
+		h.Set("Access-Control-Max-Age", "600")
+	}
+
+	h.Set("Content-Length", "0")
+	w.WriteHeader(204)
+}
+
+func applyCors(header http.Header, r *http.Request, cp *policy.CorsPolicy) {
+	if cp.Strip() {
+		for k := range header {
+			if strings.HasPrefix(k, "Access-Control-") {
+				delete(header, k)
+			}
+		}
+
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	if !cp.AllowOrigin(origin) {
+		return
+	}
+
+	expose := make([]string, 0, len(header))
+	for k := range header {
+		if strings.HasPrefix(k, "Access-Control-") || k == "Set-Cookie" {
+			continue
+		}
+
+		expose = append(expose, k)
+	}
+
+	sort.Strings(expose)
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Set("Access-Control-Allow-Credentials", "true")
+	if len(expose) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(expose, ", "))
+	}
+
+	header.Add("Vary", "Origin")
+}
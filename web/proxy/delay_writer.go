@@ -51,7 +51,7 @@ func (d *delayWriter) Write(p []byte) (n int, err error) {
 	}
 
 	switch d.delay.(type) {
-	case *policy.DelayPolicy:
+	case *policy.DelayPolicy, *policy.NetworkPolicy:
 		if !d.hasDelayed {
 			d.hasDelayed = true
 			<-time.NewTimer(d.d).C
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/net"
+	"github.com/benbearchen/asuran/policy"
+
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// contentEditor edits the whole upstream content by url settings such as
+// body-replace, json-set and encoding, and keeps failures as warnings so
+// the response would still be returned.
+type contentEditor struct {
+	bodyReplace *policy.BodyReplacePolicy
+	jsonEditors []policy.JsonEditor
+	encoding    *policy.EncodingPolicy
+	warnings    []string
+}
+
+func newContentEditor(up *policy.UrlPolicy) *contentEditor {
+	e := &contentEditor{bodyReplace: up.BodyReplace(), jsonEditors: up.JsonEditors(), encoding: up.Encoding()}
+	if e.bodyReplace == nil && len(e.jsonEditors) == 0 && e.encoding == nil {
+		return nil
+	}
+
+	return e
+}
+
+// acceptEncoding is the Accept-Encoding to send upstream.
+func (e *contentEditor) acceptEncoding() string {
+	if e.encoding != nil {
+		return e.encoding.Encoding()
+	}
+
+	return "gzip, deflate, br"
+}
+
+func (e *contentEditor) warn(w string) {
+	e.warnings = append(e.warnings, w)
+}
+
+func (e *contentEditor) Edit(header http.Header, content []byte) ([]byte, error) {
+	if len(content) == 0 {
+		return content, nil
+	}
+
+	c, err := net.DecodeContent(header.Get("Content-Encoding"), content)
+	if err != nil {
+		e.warn("can't decode content: " + err.Error())
+		return content, nil
+	}
+
+	header.Del("Content-Encoding")
+	if len(e.jsonEditors) > 0 {
+		var errs []error
+		c, errs = policy.EditJsonContent(c, e.jsonEditors)
+		for _, err := range errs {
+			e.warn(err.Error())
+		}
+	}
+
+	if e.bodyReplace != nil {
+		c = e.bodyReplace.Replace(c)
+	}
+
+	if e.encoding != nil {
+		c = e.encode(header, c)
+	}
+
+	return c, nil
+}
+
+// encode never encodes a partial content, whose range is of the original.
+func (e *contentEditor) encode(header http.Header, content []byte) []byte {
+	encoding := e.encoding.Encoding()
+	if encoding == policy.EncodingIdentity {
+		return content
+	} else if len(header.Get("Content-Range")) > 0 {
+		e.warn("encoding " + encoding + " skipped for partial content")
+		return content
+	}
+
+	c, err := net.EncodeContent(encoding, content)
+	if err != nil {
+		e.warn("can't encode content: " + err.Error())
+		return content
+	}
+
+	header.Set("Content-Encoding", encoding)
+	return c
+}
+
+// editRequestBody replaces the body of r by rb, and returns the original
+// body and warnings. The original body would be sent on failure.
+func editRequestBody(r *http.Request, rb *policy.RequestBodyPolicy) ([]byte, []string) {
+	var original []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(b))
+			return b, []string{"can't read request body: " + err.Error()}
+		}
+
+		original = b
+	} else {
+		original = []byte{}
+	}
+
+	body := original
+	encoding := r.Header.Get("Content-Encoding")
+	var warnings []string
+	if rb.Whole() {
+		r.Header.Del("Content-Encoding")
+	} else if c, err := net.DecodeContent(encoding, original); err != nil {
+		warnings = append(warnings, "can't decode request body: "+err.Error())
+		body = nil
+	} else {
+		r.Header.Del("Content-Encoding")
+		body = c
+	}
+
+	if body != nil {
+		var errs []error
+		body, errs = rb.Edit(body)
+		for _, err := range errs {
+			warnings = append(warnings, "request-body: "+err.Error())
+		}
+	} else {
+		body = original
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return original, warnings
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/net"
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEditedContentLength(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	p, err := policy.Factory("url body-replace /hello/hello,world/ g.cn")
+	if err != nil {
+		t.Fatalf("Factory failed: %v", err)
+	}
+
+	r, _ := http.NewRequest("GET", s.URL, nil)
+	resp, _, _, err := net.NewHttp(s.URL, r, nil, true)
+	if err != nil {
+		t.Fatalf("NewHttp failed: %v", err)
+	} else if resp.Header().Get("Content-Length") != "5" {
+		t.Fatalf("upstream Content-Length: %s", resp.Header().Get("Content-Length"))
+	}
+
+	editor := newContentEditor(p.(*policy.UrlPolicy))
+	content, err := resp.ProxyReturn(httptest.NewRecorder(), nil, false, false, editor.Edit)
+	resp.Close()
+	if err != nil || string(content) != "hello,world" {
+		t.Fatalf("ProxyReturn() = %q, %v", content, err)
+	}
+
+	c := cache.NewCache()
+	c.Save(cache.NewUrlCache(s.URL, r, nil, resp, "", content, "", time.Now(), time.Now(), nil), true)
+	w := httptest.NewRecorder()
+	c.Take(s.URL, "", nil).Response(w, nil)
+	if w.Header().Get("Content-Length") != "11" || w.Body.String() != "hello,world" {
+		t.Errorf("replay Content-Length %s for %q", w.Header().Get("Content-Length"), w.Body.String())
+	}
+}
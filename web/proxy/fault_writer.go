@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	gonet "github.com/benbearchen/asuran/net"
+	"github.com/benbearchen/asuran/policy"
+
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// faultBody tells fault writers the size of the whole body, which is set
+// before writing, or read from Content-Length of header.
+type faultBody struct {
+	total  int64
+	header http.Header
+}
+
+func newFaultBody(header http.Header) *faultBody {
+	return &faultBody{-1, header}
+}
+
+func (b *faultBody) setTotal(total int) {
+	b.total = int64(total)
+}
+
+func (b *faultBody) size() int64 {
+	if b.total >= 0 {
+		return b.total
+	}
+
+	if n, err := strconv.ParseInt(b.header.Get("Content-Length"), 10, 64); err == nil {
+		return n
+	}
+
+	return -1
+}
+
+// recordEdit wraps edit of HttpResponse.ProxyReturn to learn the size of
+// body, and it forces ProxyReturn to receive the whole body first.
+func (b *faultBody) recordEdit(edit func(http.Header, []byte) ([]byte, error)) func(http.Header, []byte) ([]byte, error) {
+	return func(h http.Header, content []byte) ([]byte, error) {
+		var err error
+		if edit != nil {
+			var edited []byte
+			edited, err = edit(h, content)
+			if err == nil {
+				content = edited
+			}
+		}
+
+		b.setTotal(len(content))
+		return content, err
+	}
+}
+
+func needFaultTotal(up *policy.UrlPolicy) bool {
+	if up == nil {
+		return false
+	}
+
+	c := up.Cut()
+	s := up.Stall()
+	return (c != nil && c.Percent()) || (s != nil && s.Percent())
+}
+
+// wrapFaultWriter wraps w with cut, stall and corrupt, the innermost
+// writers of all, so that offsets are counted on what client receives.
+func (p *Proxy) wrapFaultWriter(up *policy.UrlPolicy, w io.Writer, body *faultBody) io.Writer {
+	if up == nil {
+		return w
+	}
+
+	if c := up.Cut(); c != nil {
+		w = &cutWriter{cut: c, w: w, body: body}
+	}
+
+	if s := up.Stall(); s != nil {
+		w = &stallWriter{stall: s, w: w, body: body}
+	}
+
+	if c := up.Corrupt(); c != nil && c.Rate() > 0 {
+		w = &corruptWriter{rate: c.Rate(), w: w, r: p.r}
+	}
+
+	return w
+}
+
+func flushWriter(w io.Writer) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// resetConn closes conn with RST rather than FIN if it can.
+func resetConn(conn net.Conn) {
+	if l, ok := conn.(interface {
+		SetLinger(sec int) error
+	}); ok {
+		l.SetLinger(0)
+	}
+
+	conn.Close()
+}
+
+type cutWriter struct {
+	cut     *policy.CutPolicy
+	w       io.Writer
+	body    *faultBody
+	written int64
+	offset  int64
+	init    bool
+	done    bool
+}
+
+func (c *cutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return gonet.TryHijack(c.w)
+}
+
+func (c *cutWriter) Flush() {
+	flushWriter(c.w)
+}
+
+func (c *cutWriter) Write(p []byte) (int, error) {
+	if !c.init {
+		c.init = true
+		c.offset = c.cut.Offset(c.body.size())
+	}
+
+	if c.done {
+		return 0, fmt.Errorf("connection cut at %d bytes", c.written)
+	} else if c.offset < 0 || c.written+int64(len(p)) < c.offset {
+		n, err := c.w.Write(p)
+		c.written += int64(n)
+		return n, err
+	}
+
+	n, err := c.w.Write(p[:c.offset-c.written])
+	c.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	c.done = true
+	flushWriter(c.w)
+	if conn, _, err := gonet.TryHijack(c.w); err == nil {
+		resetConn(conn)
+	}
+
+	return n, fmt.Errorf("connection cut at %d bytes", c.written)
+}
+
+type stallWriter struct {
+	stall   *policy.StallPolicy
+	w       io.Writer
+	body    *faultBody
+	written int64
+	offset  int64
+	init    bool
+	stalled bool
+}
+
+func (s *stallWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return gonet.TryHijack(s.w)
+}
+
+func (s *stallWriter) Flush() {
+	flushWriter(s.w)
+}
+
+func (s *stallWriter) Write(p []byte) (int, error) {
+	if !s.init {
+		s.init = true
+		s.offset = s.stall.Offset(s.body.size())
+	}
+
+	if s.stalled || s.offset < 0 || s.written+int64(len(p)) < s.offset {
+		n, err := s.w.Write(p)
+		s.written += int64(n)
+		return n, err
+	}
+
+	n, err := s.w.Write(p[:s.offset-s.written])
+	s.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	s.stalled = true
+	flushWriter(s.w)
+	<-time.NewTimer(s.stall.Duration()).C
+
+	m, err := s.w.Write(p[n:])
+	s.written += int64(m)
+	return n + m, err
+}
+
+type corruptWriter struct {
+	rate float32
+	w    io.Writer
+	r    *rand.Rand
+}
+
+func (c *corruptWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return gonet.TryHijack(c.w)
+}
+
+func (c *corruptWriter) Flush() {
+	flushWriter(c.w)
+}
+
+// Write never changes p, which may be cached by caller.
+func (c *corruptWriter) Write(p []byte) (int, error) {
+	var b []byte
+	for i := range p {
+		if c.r.Float32() >= c.rate {
+			continue
+		}
+
+		if b == nil {
+			b = make([]byte, len(p))
+			copy(b, p)
+		}
+
+		b[i] ^= byte(1 + c.r.Intn(255))
+	}
+
+	if b == nil {
+		b = p
+	}
+
+	return c.w.Write(b)
+}
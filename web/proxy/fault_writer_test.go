@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/benbearchen/asuran/policy"
+
+	"bytes"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+func TestFaultWriter(t *testing.T) {
+	p := &Proxy{r: rand.New(rand.NewSource(1))}
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	write := func(cmd string) (*bytes.Buffer, time.Duration, error) {
+		up, err := policy.FactoryUrl(cmd)
+		if err != nil {
+			t.Fatalf("url(%s) failed: %v", cmd, err)
+		}
+
+		b := new(bytes.Buffer)
+		faults := newFaultBody(http.Header{})
+		faults.setTotal(len(content))
+		w := p.wrapFaultWriter(up, b, faults)
+		start := time.Now()
+		for i := 0; i < len(content); i += 300 {
+			end := i + 300
+			if end > len(content) {
+				end = len(content)
+			}
+
+			if _, err = w.Write(content[i:end]); err != nil {
+				break
+			}
+		}
+
+		return b, time.Now().Sub(start), err
+	}
+
+	if b, _, err := write("url cut 450 g.cn"); err == nil || b.Len() != 450 {
+		t.Errorf("cut 450 wrote %d bytes, err: %v", b.Len(), err)
+	}
+
+	if b, _, err := write("url cut 10% g.cn"); err == nil || b.Len() != 100 {
+		t.Errorf("cut 10%% wrote %d bytes, err: %v", b.Len(), err)
+	}
+
+	if b, d, err := write("url stall 50% 100ms g.cn"); err != nil || !bytes.Equal(b.Bytes(), content) || d < 100*time.Millisecond {
+		t.Errorf("stall 50%% 100ms wrote %d bytes in %v, err: %v", b.Len(), d, err)
+	}
+
+	orig := append([]byte{}, content...)
+	b, _, err := write("url corrupt 10% g.cn")
+	if err != nil || b.Len() != len(content) || bytes.Equal(b.Bytes(), content) {
+		t.Errorf("corrupt 10%% wrote %d bytes, err: %v", b.Len(), err)
+	} else if !bytes.Equal(orig, content) {
+		t.Errorf("corrupt changed the source")
+	}
+}
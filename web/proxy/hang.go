@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/net"
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+	"github.com/benbearchen/asuran/web/proxy/life"
+
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// hang holds the connection without response, until the duration passes,
+// the profile restarts or the client closes it.
+func (p *Proxy) hang(fullUrl string, w http.ResponseWriter, r *http.Request, rangeInfo string, up *policy.UrlPolicy, f *life.Life, in *life.Incoming) {
+	start := time.Now()
+	hp := up.Hang()
+	conn, rw, err := net.TryHijack(w)
+	if err != nil {
+		http.Error(w, "hang failed: "+err.Error(), 502)
+		return
+	}
+
+	defer conn.Close()
+
+	if hp.Partial() {
+		rw.WriteString(r.Proto + " 200 OK\r\nContent-Type: text/plain\r\n")
+		rw.Flush()
+	}
+
+	if in != nil {
+		in.SetAct("hang")
+	}
+
+	if f != nil {
+		f.Log("proxy " + fullUrl + " hang")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, rw)
+		close(closed)
+	}()
+
+	var timeout <-chan time.Time
+	if d := hp.Duration(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var restart <-chan struct{}
+	if f != nil {
+		restart = f.RestartSignal()
+	}
+
+	reason := ""
+	select {
+	case <-timeout:
+		reason = "timeout"
+	case <-restart:
+		reason = "restart"
+	case <-closed:
+		reason = "closed by client"
+	}
+
+	if f != nil {
+		f.Log("proxy " + fullUrl + " hang released: " + reason)
+		c := cache.NewUrlCache(fullUrl, r, nil, nil, "hang", nil, rangeInfo, start, time.Now(), fmt.Errorf("hang released: %s", reason))
+		c.Policy = up.Command()
+		go p.saveContentToCache(fullUrl, f, c, false)
+	}
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/profile"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+	"github.com/benbearchen/asuran/web/proxy/har"
+	"github.com/benbearchen/asuran/web/proxy/life"
+
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// exportHar writes histories of f as HAR, filtered as search.json.
+func (p *Proxy) exportHar(w http.ResponseWriter, r *http.Request, profileIP string, f *life.Life) {
+	r.ParseForm()
+	filter, err := cache.ParseHistoryFilter(r.Form)
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	h := har.New(p.ver, f.Histories(), filter)
+	if n := f.HistoryUsage().Omitted(); n > 0 {
+		h.Log.Comment = fmt.Sprintf("%d older histories out of memory are not exported", n)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+profileIP+`.har"`)
+	h.Write(w)
+}
+
+type HarImport struct {
+	Stores   []string `json:"stores"`
+	Commands string   `json:"commands"`
+	Errors   []string `json:"errors"`
+	Pack     string   `json:"pack,omitempty"`
+}
+
+// ImportHar stores response bodies of the HAR for prof, and restores them
+// by url commands with status and headers. With packName, the commands are
+// also saved as a pack, whose contents are inline for stores are of prof.
+func (p *Proxy) ImportHar(prof *profile.Profile, r io.Reader, packName, author string) (*HarImport, error) {
+	h, err := har.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mocks, collisions, err := h.Mocks()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HarImport{Stores: make([]string, 0, len(mocks))}
+	inline := ""
+	for _, m := range mocks {
+		sid := prof.StoreID(m.Content)
+		result.Stores = append(result.Stores, sid)
+		result.Commands += m.Command("restore "+sid) + "\n"
+		if len(m.Content) > 0 {
+			inline += m.Command("rewrite "+url.QueryEscape(string(m.Content))) + "\n"
+		} else {
+			inline += m.Command("") + "\n"
+		}
+	}
+
+	result.Errors = append(collisions, p.Command(result.Commands, prof, nil)...)
+	if len(packName) > 0 {
+		if len(author) == 0 {
+			author = prof.Ip
+		}
+
+		if err := p.packs.Save(packName, author, "HAR import", inline); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("save pack %s failed: %v", packName, err))
+		} else {
+			result.Pack = packName
+		}
+	}
+
+	return result, nil
+}
+
+type harImportData struct {
+	IP     string
+	Result *HarImport
+	Error  string
+}
+
+// importHar imports a HAR posted by the form, as the file "har", or by the
+// API, as the body of ".../har/import" which answers json.
+func (p *Proxy) importHar(w http.ResponseWriter, r *http.Request, profileIP string, prof *profile.Profile, api bool) {
+	var body io.Reader = r.Body
+	packName := r.URL.Query().Get("pack")
+	author := r.URL.Query().Get("author")
+	if !api {
+		file, _, err := r.FormFile("har")
+		if err != nil {
+			p.writeHarImport(w, harImportData{profileIP, nil, "请选择 HAR 文件"})
+			return
+		}
+
+		defer file.Close()
+		body = file
+		packName = r.FormValue("pack")
+		author = r.FormValue("author")
+	}
+
+	result, err := p.ImportHar(prof, body, packName, author)
+	if api {
+		if err != nil {
+			w.WriteHeader(400)
+			fmt.Fprintln(w, "import HAR failed:", err)
+			return
+		}
+
+		bytes, _ := json.Marshal(result)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(bytes)
+		return
+	}
+
+	data := harImportData{profileIP, result, ""}
+	if err != nil {
+		data.Error = err.Error()
+	}
+
+	p.writeHarImport(w, data)
+}
+
+func (p *Proxy) writeHarImport(w http.ResponseWriter, data harImportData) {
+	t, err := template.ParseFiles("template/har-import.tmpl")
+	err = t.Execute(w, data)
+	if err != nil {
+		fmt.Fprintln(w, "内部错误：", err)
+	}
+}
@@ -0,0 +1,256 @@
+package har
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR 1.2, http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	Comment         string   `json:"comment,omitempty"`
+
+	// custom fields of asuran
+	ID       uint32 `json:"_id,omitempty"`
+	Policy   string `json:"_policy,omitempty"`
+	Source   string `json:"_source,omitempty"`
+	Error    string `json:"_error,omitempty"`
+	ResendOf uint32 `json:"_resendOf,omitempty"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type Content struct {
+	Size        int    `json:"size"`
+	Compression int    `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+// Timings are in milliseconds, -1 for unknown.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// New makes the HAR of histories which match filter.
+func New(version string, histories []*cache.UrlHistory, filter *cache.HistoryFilter) *HAR {
+	h := &HAR{Log{"1.2", Creator{"asuran", version}, make([]Entry, 0, len(histories)), ""}}
+	for _, c := range histories {
+		if filter == nil || filter.Match(c) {
+			h.Log.Entries = append(h.Log.Entries, NewEntry(c))
+		}
+	}
+
+	return h
+}
+
+func (h *HAR) Write(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(h)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func NewEntry(h *cache.UrlHistory) Entry {
+	e := Entry{}
+	e.StartedDateTime = h.Time.Format("2006-01-02T15:04:05.000Z07:00")
+	e.Time = ms(h.Duration)
+	e.Request = newRequest(&h.UrlCache)
+	e.Response = newResponse(&h.UrlCache)
+	e.Timings = Timings{ms(h.UploadDuration), ms(h.Duration - h.UploadDuration), 0}
+	if e.Timings.Wait < 0 {
+		e.Timings.Wait = 0
+	}
+
+	e.ID = h.ID
+	e.Policy = h.Policy
+	e.Source = h.ContentSource
+	e.ResendOf = h.ResendOf
+	if h.Error != nil {
+		e.Error = h.Error.Error()
+	}
+
+	if len(h.Warnings) > 0 {
+		e.Comment = strings.Join(h.Warnings, "\n")
+	}
+
+	return e
+}
+
+func newRequest(c *cache.UrlCache) Request {
+	r := Request{}
+	r.Method = c.Method
+	r.URL = c.Url
+	r.HTTPVersion = "HTTP/1.1"
+	r.Cookies = requestCookies(c.RequestHeader)
+	r.Headers = headers(c.RequestHeader)
+	r.QueryString = make([]NameValue, 0)
+	if u, err := url.Parse(c.Url); err == nil {
+		r.QueryString = values(u.Query())
+	}
+
+	r.HeadersSize = -1
+	r.BodySize = len(c.PostBody)
+	if c.PostBody != nil {
+		text, encoding := textOf(c.PostBody)
+		r.PostData = &PostData{c.RequestHeader.Get("Content-Type"), text, encoding}
+	}
+
+	return r
+}
+
+func newResponse(c *cache.UrlCache) Response {
+	r := Response{}
+	r.Status = c.ResponseCode
+	if r.Status < 0 || c.Error != nil && len(c.ResponseHeader) == 0 {
+		r.Status = 0
+	}
+
+	r.StatusText = http.StatusText(r.Status)
+	r.HTTPVersion = "HTTP/1.1"
+	r.Cookies = responseCookies(c.ResponseHeader)
+	r.Headers = headers(c.ResponseHeader)
+	r.RedirectURL = c.ResponseHeader.Get("Location")
+	r.HeadersSize = -1
+	r.BodySize = len(c.Bytes)
+
+	r.Content.MimeType = c.ResponseHeader.Get("Content-Type")
+	content := c.Bytes
+	if c.Error == nil {
+		if d, err := c.Content(); err == nil {
+			content = d
+		}
+	}
+
+	r.Content.Size = len(content)
+	r.Content.Compression = len(content) - len(c.Bytes)
+	if len(content) > 0 {
+		r.Content.Text, r.Content.Encoding = textOf(content)
+	}
+
+	return r
+}
+
+// textOf returns utf-8 text as is, or else base64 encoded.
+func textOf(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func headers(h http.Header) []NameValue {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	nv := make([]NameValue, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range h[k] {
+			nv = append(nv, NameValue{k, v})
+		}
+	}
+
+	return nv
+}
+
+func values(v url.Values) []NameValue {
+	return headers(http.Header(v))
+}
+
+func requestCookies(h http.Header) []Cookie {
+	r := http.Request{Header: h}
+	cookies := make([]Cookie, 0)
+	for _, c := range r.Cookies() {
+		cookies = append(cookies, Cookie{c.Name, c.Value})
+	}
+
+	return cookies
+}
+
+func responseCookies(h http.Header) []Cookie {
+	r := http.Response{Header: h}
+	cookies := make([]Cookie, 0)
+	for _, c := range r.Cookies() {
+		cookies = append(cookies, Cookie{c.Name, c.Value})
+	}
+
+	return cookies
+}
@@ -0,0 +1,118 @@
+package har
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func TestNewEntry(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("hello"))
+	w.Close()
+
+	reqHeader := http.Header{}
+	reqHeader.Set("Content-Type", "application/octet-stream")
+	reqHeader.Set("Cookie", "a=1; b=2")
+	respHeader := http.Header{}
+	respHeader.Set("Content-Encoding", "gzip")
+	respHeader.Set("Content-Type", "text/plain")
+	respHeader.Add("Set-Cookie", "s=3")
+	start := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	h := &cache.UrlHistory{UrlCache: cache.UrlCache{
+		Time:           start,
+		Duration:       1500 * time.Millisecond,
+		Url:            "http://g.cn/s?q=1&q=2",
+		Method:         "POST",
+		RequestHeader:  reqHeader,
+		PostBody:       []byte{0xff, 0xfe},
+		Bytes:          gz.Bytes(),
+		ResponseHeader: respHeader,
+		ResponseCode:   200,
+		UploadDuration: 500 * time.Millisecond,
+		Policy:         "url delay 1s g.cn/s",
+	}, ID: 7}
+
+	e := NewEntry(h)
+	if e.StartedDateTime != "2026-10-19T08:00:00.000Z" || e.Time != 1500 || e.Timings.Send != 500 || e.Timings.Wait != 1000 {
+		t.Errorf("entry time wrong: %v %v %v", e.StartedDateTime, e.Time, e.Timings)
+	}
+
+	if e.ID != 7 || e.Policy != "url delay 1s g.cn/s" || e.Error != "" {
+		t.Errorf("entry custom fields wrong: %v", e)
+	}
+
+	r := e.Request
+	if r.Method != "POST" || fmt.Sprint(r.QueryString) != "[{q 1} {q 2}]" || len(r.Cookies) != 2 || r.Cookies[1].Value != "2" {
+		t.Errorf("request wrong: %v", r)
+	} else if r.PostData == nil || r.PostData.Text != "//4=" || r.PostData.Encoding != "base64" || r.BodySize != 2 {
+		t.Errorf("request post data wrong: %v", r.PostData)
+	}
+
+	p := e.Response
+	if p.Status != 200 || p.StatusText != "OK" || len(p.Cookies) != 1 || p.Cookies[0].Name != "s" {
+		t.Errorf("response wrong: %v", p)
+	} else if p.Content.Text != "hello" || p.Content.Size != 5 || p.Content.MimeType != "text/plain" || p.BodySize != len(gz.Bytes()) || p.Content.Compression != 5-len(gz.Bytes()) {
+		t.Errorf("response content wrong: %v", p.Content)
+	}
+
+	h.Error = fmt.Errorf("refused")
+	h.ResponseCode = -1
+	h.ResponseHeader = nil
+	h.Bytes = nil
+	e = NewEntry(h)
+	if e.Response.Status != 0 || e.Error != "refused" || e.Response.Content.Size != 0 {
+		t.Errorf("error entry wrong: %v", e.Response)
+	}
+}
+
+func TestNewHAR(t *testing.T) {
+	start := time.Now()
+	histories := make([]*cache.UrlHistory, 0)
+	for i, u := range []string{"http://a.cn/1", "http://b.cn/2", "http://a.cn/3"} {
+		c := cache.UrlCache{Time: start.Add(time.Duration(i) * time.Minute), Url: u, Method: "GET", ResponseCode: 200}
+		histories = append(histories, &cache.UrlHistory{UrlCache: c, ID: uint32(i + 1)})
+	}
+
+	ids := func(f *cache.HistoryFilter) string {
+		h := New("test", histories, f)
+		s := ""
+		for _, e := range h.Log.Entries {
+			s += fmt.Sprint(e.ID)
+		}
+
+		return s
+	}
+
+	if s := ids(nil); s != "123" {
+		t.Errorf("all entries wrong: %s", s)
+	}
+
+	if s := ids(&cache.HistoryFilter{Url: "a.cn"}); s != "13" {
+		t.Errorf("url filter wrong: %s", s)
+	}
+
+	if s := ids(&cache.HistoryFilter{Since: start.Add(time.Second), Until: start.Add(2 * time.Minute)}); s != "23" {
+		t.Errorf("time filter wrong: %s", s)
+	}
+
+	var b bytes.Buffer
+	if err := New("test", histories, nil).Write(&b); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	var h HAR
+	if err := json.Unmarshal(b.Bytes(), &h); err != nil || h.Log.Version != "1.2" || h.Log.Creator.Name != "asuran" || len(h.Log.Entries) != 3 {
+		t.Errorf("HAR json wrong: %v, %s", err, b.String())
+	}
+}
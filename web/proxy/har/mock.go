@@ -0,0 +1,145 @@
+package har
+
+import (
+	"github.com/benbearchen/asuran/profile"
+
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mock is the response of the last entry of an url in HAR.
+type Mock struct {
+	Method  string
+	Url     string
+	Status  int
+	Header  http.Header
+	Content []byte
+}
+
+// headers to be remade by asuran, for contents are decoded
+var skipMockHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+func Parse(r io.Reader) (*HAR, error) {
+	h := new(HAR)
+	if err := json.NewDecoder(r).Decode(h); err != nil {
+		return nil, err
+	} else if len(h.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HAR has no entry")
+	}
+
+	return h, nil
+}
+
+// Mocks makes mocks in order of urls first seen. Entries without response,
+// such as failed or 304, are skipped, and so are OPTIONS and HEAD, which
+// have no content of the url. As url commands don't tell methods apart,
+// the last entry of an url wins, and collisions tell the urls answered
+// with other methods before.
+func (h *HAR) Mocks() (mocks []*Mock, collisions []string, err error) {
+	mocks = make([]*Mock, 0, len(h.Log.Entries))
+	index := make(map[string]int)
+	for _, e := range h.Log.Entries {
+		s := e.Response.Status
+		switch e.Request.Method {
+		case "CONNECT", "OPTIONS", "HEAD":
+			continue
+		}
+
+		if s <= 0 || s == 304 {
+			continue
+		}
+
+		m, err := newMock(&e)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if i, ok := index[m.Url]; ok {
+			if old := mocks[i]; old.Method != m.Method {
+				collisions = append(collisions, fmt.Sprintf("%s %s overrides %s", m.Method, m.Url, old.Method))
+			}
+
+			mocks[i] = m
+		} else {
+			index[m.Url] = len(mocks)
+			mocks = append(mocks, m)
+		}
+	}
+
+	return mocks, collisions, nil
+}
+
+func newMock(e *Entry) (*Mock, error) {
+	m := &Mock{e.Request.Method, e.Request.URL, e.Response.Status, make(http.Header), []byte{}}
+	for _, nv := range e.Response.Headers {
+		if strings.HasPrefix(nv.Name, ":") {
+			continue // pseudo headers of HTTP/2
+		}
+
+		k := textproto.CanonicalMIMEHeaderKey(nv.Name)
+		if !skipMockHeaders[k] {
+			m.Header.Add(k, nv.Value)
+		}
+	}
+
+	c := e.Response.Content
+	if c.Encoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(c.Text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content of %s: %v", m.Url, err)
+		}
+
+		m.Content = b
+	} else if len(c.Text) > 0 {
+		m.Content = []byte(c.Text)
+	}
+
+	return m, nil
+}
+
+// Command makes the url command of m, content is the content setting such
+// as "restore <store-id>", or empty for none.
+func (m *Mock) Command(content string) string {
+	c := []string{"url"}
+	if len(content) > 0 {
+		c = append(c, content)
+	}
+
+	if m.Status != 200 || len(content) == 0 {
+		c = append(c, "status", strconv.Itoa(m.Status))
+	}
+
+	if len(m.Header) > 0 {
+		keys := make([]string, 0, len(m.Header))
+		for k := range m.Header {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		lines := make([]string, 0, len(keys))
+		for _, k := range keys {
+			lines = append(lines, "-"+k)
+			for _, v := range m.Header[k] {
+				lines = append(lines, "+"+k+":"+v)
+			}
+		}
+
+		c = append(c, "response-headers", url.QueryEscape(strings.Join(lines, "\n")))
+	}
+
+	return strings.Join(append(c, profile.UrlToPattern(m.Url)), " ")
+}
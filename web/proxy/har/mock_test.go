@@ -0,0 +1,126 @@
+package har
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+const testHar = `{"log": {"version": "1.2", "creator": {"name": "devtools", "version": "1"}, "entries": [
+{"request": {"method": "GET", "url": "http://g.cn/a?x=1"}, "response": {"status": 200,
+  "headers": [{"name": ":status", "value": "200"}, {"name": "content-type", "value": "text/plain"}, {"name": "content-encoding", "value": "gzip"}, {"name": "x-v", "value": "1"}, {"name": "x-v", "value": "2 3"}],
+  "content": {"size": 5, "text": "hello"}}},
+{"request": {"method": "GET", "url": "http://g.cn/b"}, "response": {"status": 0, "content": {}}},
+{"request": {"method": "GET", "url": "http://g.cn/c"}, "response": {"status": 304, "content": {}}},
+{"request": {"method": "POST", "url": "https://g.cn/d"}, "response": {"status": 201, "content": {"text": "//4=", "encoding": "base64"}}},
+{"request": {"method": "GET", "url": "http://g.cn/a?x=1"}, "response": {"status": 404, "content": {"text": "gone"}}},
+{"request": {"method": "GET", "url": "http://g.cn/e"}, "response": {"status": 204, "content": {"size": 0}}},
+{"request": {"method": "OPTIONS", "url": "https://g.cn/d"}, "response": {"status": 204, "content": {"size": 0}}},
+{"request": {"method": "HEAD", "url": "http://g.cn/e"}, "response": {"status": 200, "content": {"size": 0}}},
+{"request": {"method": "PUT", "url": "http://g.cn/f"}, "response": {"status": 200, "content": {"text": "put"}}},
+{"request": {"method": "GET", "url": "http://g.cn/f"}, "response": {"status": 200, "content": {"text": "get"}}}
+]}}`
+
+func TestMocks(t *testing.T) {
+	h, err := Parse(strings.NewReader(testHar))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	mocks, collisions, err := h.Mocks()
+	if err != nil {
+		t.Fatalf("Mocks() failed: %v", err)
+	} else if len(mocks) != 4 {
+		t.Fatalf("Mocks() should have 4: %v", mocks)
+	} else if len(collisions) != 1 || collisions[0] != "GET http://g.cn/f overrides PUT" {
+		t.Errorf("Mocks() collisions wrong: %v", collisions)
+	}
+
+	a, d, e, f := mocks[0], mocks[1], mocks[2], mocks[3]
+	if a.Url != "http://g.cn/a?x=1" || a.Status != 404 || string(a.Content) != "gone" || len(a.Header) != 0 {
+		t.Errorf("mock a should be the last: %v", a)
+	}
+
+	if d.Url != "https://g.cn/d" || d.Status != 201 || !bytes.Equal(d.Content, []byte{0xff, 0xfe}) {
+		t.Errorf("mock d wrong: %v", d)
+	}
+
+	if e.Status != 204 || len(e.Content) != 0 {
+		t.Errorf("mock e wrong: %v", e)
+	}
+
+	if f.Method != "GET" || string(f.Content) != "get" {
+		t.Errorf("mock f should be the last: %v", f)
+	}
+
+	if _, err := Parse(strings.NewReader(`{"log": {"entries": []}}`)); err == nil {
+		t.Errorf("Parse() should fail without entries")
+	}
+}
+
+func TestMockCommand(t *testing.T) {
+	h, _ := Parse(strings.NewReader(testHar))
+	h.Log.Entries = h.Log.Entries[:1]
+	mocks, _, _ := h.Mocks()
+	m := mocks[0]
+	if len(m.Header) != 2 || m.Header.Get("Content-Type") != "text/plain" || strings.Join(m.Header["X-V"], ",") != "1,2 3" {
+		t.Fatalf("mock headers wrong: %v", m.Header)
+	}
+
+	check := func(content, prefix string, status *int) {
+		cmd := m.Command(content)
+		if !strings.HasPrefix(cmd, prefix) || !strings.HasSuffix(cmd, " g.cn/a?x=1") {
+			t.Errorf("Command(%s) wrong: %s", content, cmd)
+			return
+		}
+
+		p, err := policy.Factory(cmd)
+		if err != nil {
+			t.Errorf("Factory(%s) failed: %v", cmd, err)
+			return
+		}
+
+		up := p.(*policy.UrlPolicy)
+		if (status == nil) != (up.Status() == nil) || status != nil && up.Status().StatusCode() != *status {
+			t.Errorf("Command(%s) status wrong: %s", content, cmd)
+		}
+
+		header := http.Header{"Content-Type": {"text/html"}, "X-V": {"0"}}
+		up.ResponseHeaders().Apply(header)
+		if strings.Join(header["Content-Type"], ",") != "text/plain" || strings.Join(header["X-V"], ",") != "1,2 3" {
+			t.Errorf("Command(%s) headers wrong: %v", content, header)
+		}
+	}
+
+	ok, notFound := 200, 404
+	check("restore s1", "url restore s1 response-headers ", nil)
+	check("", "url status 200 response-headers ", &ok)
+
+	m.Status = 404
+	check("rewrite hello", "url rewrite hello status 404 response-headers ", &notFound)
+}
+
+func TestExportImport(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/plain")
+	c := cache.UrlCache{Url: "http://g.cn/x", Method: "GET", ResponseHeader: header, ResponseCode: 500, Bytes: []byte("oops")}
+	var b bytes.Buffer
+	New("test", []*cache.UrlHistory{{UrlCache: c, ID: 1}}, nil).Write(&b)
+
+	h, err := Parse(&b)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	mocks, _, _ := h.Mocks()
+	if len(mocks) != 1 || mocks[0].Status != 500 || string(mocks[0].Content) != "oops" || mocks[0].Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("import exported HAR wrong: %v", mocks)
+	}
+}
@@ -395,6 +395,7 @@ type deviceData struct {
 	InitTime   string
 	VisitTime  string
 	ActiveTime string
+	History    string
 }
 
 type devicesListData struct {
@@ -423,6 +424,7 @@ func formatDevicesListData(profiles []*profile.Profile, v *life.IPLives) devices
 			it := ""
 			vt := ""
 			at := ""
+			hu := ""
 			f := v.OpenExists(p.Ip)
 			if f != nil {
 				format := func(t time.Time) string {
@@ -436,17 +438,41 @@ func formatDevicesListData(profiles []*profile.Profile, v *life.IPLives) devices
 				it = format(f.CreateTime)
 				vt = format(f.VisitTime)
 				at = format(f.ActiveTime)
+				hu = formatHistoryUsage(f.HistoryUsage())
 			}
 
 			even = !even
 
-			devices = append(devices, deviceData{even, p.Name, p.Ip, p.Owner, it, vt, at})
+			devices = append(devices, deviceData{even, p.Name, p.Ip, p.Owner, it, vt, at, hu})
 		}
 	}
 
 	return devicesListData{devices}
 }
 
+func formatBytes(n int64) string {
+	if n >= 1024*1024 {
+		return fmt.Sprintf("%.1fMB", float64(n)/1024/1024)
+	} else if n >= 1024 {
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	}
+
+	return fmt.Sprintf("%dB", n)
+}
+
+func formatHistoryUsage(u cache.HistoryUsage) string {
+	s := fmt.Sprintf("%d 条 %s", u.Count, formatBytes(u.Size))
+	if u.Spilled > 0 {
+		s += fmt.Sprintf("，磁盘 %d 条 %s", u.Spilled, formatBytes(u.SpilledSize))
+	}
+
+	if u.Evicted > 0 {
+		s += fmt.Sprintf("，丢弃 %d 条", u.Evicted)
+	}
+
+	return s
+}
+
 func (p *Proxy) devices(w http.ResponseWriter) {
 	t, err := template.ParseFiles("template/devices.tmpl")
 	profiles := make([]*profile.Profile, 0)
@@ -19,18 +19,21 @@ func uniqueID() string {
 type Incoming struct {
 	uniqueID string
 	start    time.Time
+	update   time.Time
 	end      time.Time
 	ender    func(id string)
+	actor    func(id, act string)
 
 	key string
 	act string
 }
 
-func newIncoming(key, act string, ender func(id string)) *Incoming {
+func newIncoming(key, act string, ender func(id string), actor func(id, act string)) *Incoming {
 	c := new(Incoming)
 	c.uniqueID = uniqueID()
 	c.start = uniqueTime()
 	c.ender = ender
+	c.actor = actor
 	c.key = key
 	c.act = act
 
@@ -40,10 +43,8 @@ func newIncoming(key, act string, ender func(id string)) *Incoming {
 func (c *Incoming) after(t time.Time) bool {
 	if t.IsZero() {
 		return true
-	} else if !c.end.IsZero() {
-		return c.end.After(t)
 	} else {
-		return c.start.After(t)
+		return c.T().After(t)
 	}
 }
 
@@ -55,6 +56,11 @@ func (c *Incoming) Done() {
 	c.ender(c.uniqueID)
 }
 
+// SetAct changes what is doing with the request, such as "hang".
+func (c *Incoming) SetAct(act string) {
+	c.actor(c.uniqueID, act)
+}
+
 func (c *Incoming) ID() string {
 	return c.uniqueID
 }
@@ -70,6 +76,8 @@ func (c *Incoming) End() time.Time {
 func (c *Incoming) T() time.Time {
 	if !c.end.IsZero() {
 		return c.end
+	} else if !c.update.IsZero() {
+		return c.update
 	} else {
 		return c.start
 	}
@@ -101,7 +109,7 @@ func (c *incomings) create(key, act string) *Incoming {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	in := newIncoming(key, act, c.ender)
+	in := newIncoming(key, act, c.ender, c.actor)
 	c.cs[in.uniqueID] = in
 	return in
 }
@@ -119,6 +127,20 @@ func (c *incomings) ender(id string) {
 	}
 }
 
+func (c *incomings) actor(id, act string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	in, ok := c.cs[id]
+	if ok && in.end.IsZero() {
+		in.act = act
+		in.update = uniqueTime()
+		go func() {
+			c.e <- in
+		}()
+	}
+}
+
 func (c *incomings) after(t time.Time) []*Incoming {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
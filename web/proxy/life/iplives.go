@@ -1,12 +1,20 @@
 package life
 
 import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
 
 type IPLives struct {
-	lives map[string]*Life
+	lives    map[string]*Life
+	spillDir string
+	limit    func(ip string) cache.HistoryLimit
+	total    cache.HistoryLimit
+	balanceC chan struct{}
 
 	lock sync.RWMutex
 }
@@ -14,10 +22,37 @@ type IPLives struct {
 func NewIPLives() *IPLives {
 	lives := IPLives{}
 	lives.lives = make(map[string]*Life)
+	lives.balanceC = make(chan struct{}, 1)
 	go lives.run()
 	return &lives
 }
 
+// SetHistoryLimit limits histories of lives opened later by limit of their
+// ip, spilled to sub dirs of spillDir.
+func (v *IPLives) SetHistoryLimit(spillDir string, limit func(ip string) cache.HistoryLimit) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.spillDir = spillDir
+	v.limit = limit
+}
+
+// SetTotalHistoryLimit limits histories in memory of all lives, 0 for no
+// limit. Beyond it, lives of the most histories drop their oldest ones.
+func (v *IPLives) SetTotalHistoryLimit(count int, size int64) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.total = cache.HistoryLimit{Count: count, Size: size}
+}
+
+func (v *IPLives) historySaved() {
+	select {
+	case v.balanceC <- struct{}{}:
+	default:
+	}
+}
+
 func (v *IPLives) Open(ip string) *Life {
 	if len(ip) == 0 {
 		return nil
@@ -31,7 +66,12 @@ func (v *IPLives) Open(ip string) *Life {
 	defer v.lock.Unlock()
 	f, ok := v.lives[ip]
 	if !ok {
-		f = NewLife(ip)
+		spillDir := ""
+		if len(v.spillDir) > 0 {
+			spillDir = filepath.Join(v.spillDir, ip)
+		}
+
+		f = NewLife(ip, spillDir, v.limit, v.historySaved)
 		v.lives[ip] = f
 	}
 
@@ -67,6 +107,8 @@ func (v *IPLives) run() {
 		select {
 		case <-time.NewTimer(time.Second * 15).C:
 			v.checkIdle()
+		case <-v.balanceC:
+			v.balanceHistory()
 		}
 	}
 }
@@ -80,3 +122,70 @@ func (v *IPLives) checkIdle() {
 		}
 	}
 }
+
+type lifeUsage struct {
+	f *Life
+	u cache.HistoryUsage
+}
+
+// balanceHistory shrinks histories of lives, the biggest first, until all
+// of them are within the total limit.
+func (v *IPLives) balanceHistory() {
+	v.lock.RLock()
+	total := v.total
+	lives := make([]*Life, 0, len(v.lives))
+	for _, f := range v.lives {
+		lives = append(lives, f)
+	}
+
+	v.lock.RUnlock()
+
+	if total.Count <= 0 && total.Size <= 0 {
+		return
+	}
+
+	usages := make([]lifeUsage, 0, len(lives))
+	count, size := 0, int64(0)
+	for _, f := range lives {
+		u := f.HistoryUsage()
+		usages = append(usages, lifeUsage{f, u})
+		count += u.Count
+		size += u.Size
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].u.Size != usages[j].u.Size {
+			return usages[i].u.Size > usages[j].u.Size
+		}
+
+		return usages[i].u.Count > usages[j].u.Count
+	})
+
+	for _, lu := range usages {
+		overCount, overSize := 0, int64(0)
+		if total.Count > 0 && count > total.Count {
+			overCount = count - total.Count
+		}
+
+		if total.Size > 0 && size > total.Size {
+			overSize = size - total.Size
+		}
+
+		if overCount == 0 && overSize == 0 {
+			break
+		}
+
+		n, s := lu.u.Count-overCount, lu.u.Size-overSize
+		if n < 0 {
+			n = 0
+		}
+
+		if s < 0 {
+			s = 0
+		}
+
+		u := lu.f.ShrinkHistory(n, s)
+		count -= lu.u.Count - u.Count
+		size -= lu.u.Size - u.Size
+	}
+}
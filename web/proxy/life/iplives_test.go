@@ -0,0 +1,30 @@
+package life
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"testing"
+)
+
+func TestTotalHistoryLimit(t *testing.T) {
+	v := NewIPLives()
+	v.SetTotalHistoryLimit(3, 0)
+
+	a, b := v.Open("10.0.0.1"), v.Open("10.0.0.2")
+	save := func(f *Life, n int, body string) {
+		for i := 0; i < n; i++ {
+			f.SaveContentToCache(&cache.UrlCache{Url: "http://g.cn/", Method: "GET", Bytes: []byte(body), ResponseCode: 200}, false)
+		}
+	}
+
+	save(a, 3, "aaaa")
+	save(b, 2, "b")
+	v.balanceHistory()
+
+	ua, ub := a.HistoryUsage(), b.HistoryUsage()
+	if ua.Count+ub.Count > 3 {
+		t.Errorf("total limit not enforced: %+v, %+v", ua, ub)
+	} else if ua.Count != 1 || ub.Count != 2 || ua.Evicted != 2 {
+		t.Errorf("the biggest life should shrink first: %+v, %+v", ua, ub)
+	}
+}
@@ -3,6 +3,7 @@ package life
 import (
 	"github.com/benbearchen/asuran/web/proxy/cache"
 
+	"net/http"
 	"time"
 )
 
@@ -57,11 +58,17 @@ type Life struct {
 	watching   []cWatchHistory
 	incomings  *incomings
 	inWatching []cWatchIncoming
+	restartC   chan struct{}
+	limit      func(ip string) cache.HistoryLimit
+	saved      func()
 
 	c chan interface{}
 }
 
-func NewLife(ip string) *Life {
+// NewLife makes the life of ip, whose histories are spilled to spillDir,
+// if not empty, beyond limit. saved, if not nil, is called after each
+// history is saved.
+func NewLife(ip, spillDir string, limit func(ip string) cache.HistoryLimit, saved func()) *Life {
 	f := Life{}
 	f.IP = ip
 	f.CreateTime = time.Now()
@@ -70,8 +77,15 @@ func NewLife(ip string) *Life {
 	f.urls = make(map[string]*UrlState)
 	f.domains = make(map[string]*DomainState)
 	f.cache = cache.NewCache()
+	if len(spillDir) > 0 {
+		f.cache.SetSpillDir(spillDir)
+	}
+
+	f.limit = limit
+	f.saved = saved
 	f.history = NewHistory()
 	f.watching = make([]cWatchHistory, 0)
+	f.restartC = make(chan struct{})
 
 	f.c = make(chan interface{})
 
@@ -156,6 +170,20 @@ func (f *Life) restart() {
 	f.clearHistory()
 	f.VisitTime = time.Time{}
 	f.ActiveTime = time.Time{}
+
+	close(f.restartC)
+	f.restartC = make(chan struct{})
+}
+
+type cRestartSignal struct {
+	c chan (<-chan struct{})
+}
+
+// RestartSignal returns a channel which would be closed by next Restart().
+func (f *Life) RestartSignal() <-chan struct{} {
+	c := make(chan (<-chan struct{}))
+	f.c <- cRestartSignal{c}
+	return <-c
 }
 
 type cClearHistory struct {
@@ -178,19 +206,20 @@ func (f *Life) clearHistory() {
 }
 
 type cCheckCache struct {
-	url       string
+	key       string
 	rangeInfo string
+	header    http.Header
 	c         chan *cache.UrlCache
 }
 
-func (f *Life) CheckCache(url, rangeInfo string) *cache.UrlCache {
+func (f *Life) CheckCache(key, rangeInfo string, header http.Header) *cache.UrlCache {
 	c := make(chan *cache.UrlCache)
-	f.c <- cCheckCache{url, rangeInfo, c}
+	f.c <- cCheckCache{key, rangeInfo, header, c}
 	return <-c
 }
 
-func (f *Life) checkCache(url, rangeInfo string) *cache.UrlCache {
-	return f.cache.Take(url, rangeInfo)
+func (f *Life) checkCache(key, rangeInfo string, header http.Header) *cache.UrlCache {
+	return f.cache.Take(key, rangeInfo, header)
 }
 
 type cLookCache struct {
@@ -223,6 +252,20 @@ func (f *Life) listHistory(url string) []*cache.UrlHistory {
 	return f.cache.List(url)
 }
 
+type cHistories struct {
+	c chan []*cache.UrlHistory
+}
+
+func (f *Life) Histories() []*cache.UrlHistory {
+	c := make(chan []*cache.UrlHistory)
+	f.c <- cHistories{c}
+	return <-c
+}
+
+func (f *Life) histories() []*cache.UrlHistory {
+	return f.cache.Histories()
+}
+
 type cLookHistoryByID struct {
 	id uint32
 	c  chan *cache.UrlHistory
@@ -238,6 +281,28 @@ func (f *Life) lookHistoryByID(id uint32) *cache.UrlHistory {
 	return f.cache.History(id)
 }
 
+type cFindHistory struct {
+	url   string
+	score func(h *cache.UrlHistory) int
+	c     chan *cache.UrlHistory
+}
+
+// FindHistory finds the best history by score in memory, or else the best
+// spilled one of url.
+func (f *Life) FindHistory(url string, score func(h *cache.UrlHistory) int) *cache.UrlHistory {
+	c := make(chan *cache.UrlHistory)
+	f.c <- cFindHistory{url, score, c}
+	return <-c
+}
+
+func (f *Life) findHistory(url string, score func(h *cache.UrlHistory) int) *cache.UrlHistory {
+	if h := f.cache.Find(score); h != nil {
+		return h
+	}
+
+	return f.cache.FindSpilled(url, score)
+}
+
 type cSaveContentToCache struct {
 	cache *cache.UrlCache
 	save  bool
@@ -251,7 +316,51 @@ func (f *Life) SaveContentToCache(cache *cache.UrlCache, save bool) uint32 {
 }
 
 func (f *Life) saveContentToCache(cache *cache.UrlCache, save bool) uint32 {
-	return f.cache.Save(cache, save)
+	id := f.cache.Save(cache, save)
+	if f.limit != nil {
+		f.cache.Limit(f.limit(f.IP))
+	}
+
+	if f.saved != nil {
+		f.saved()
+	}
+
+	return id
+}
+
+type cShrinkHistory struct {
+	count int
+	size  int64
+	c     chan cache.HistoryUsage
+}
+
+// ShrinkHistory drops the oldest histories in memory until they are within
+// count and size, and returns the usage after. They are spilled or evicted
+// as the limit of the life.
+func (f *Life) ShrinkHistory(count int, size int64) cache.HistoryUsage {
+	c := make(chan cache.HistoryUsage)
+	f.c <- cShrinkHistory{count, size, c}
+	return <-c
+}
+
+func (f *Life) shrinkHistory(count int, size int64) cache.HistoryUsage {
+	evict := false
+	if f.limit != nil {
+		evict = f.limit(f.IP).Evict
+	}
+
+	f.cache.Shrink(count, size, evict)
+	return f.cache.Usage()
+}
+
+type cHistoryUsage struct {
+	c chan cache.HistoryUsage
+}
+
+func (f *Life) HistoryUsage() cache.HistoryUsage {
+	c := make(chan cache.HistoryUsage)
+	f.c <- cHistoryUsage{c}
+	return <-c
 }
 
 type cLog struct {
@@ -401,18 +510,28 @@ func (f *Life) work() {
 			e.c <- f.openDomain(e.domain)
 		case cRestart:
 			f.restart()
+		case cRestartSignal:
+			e.c <- f.restartC
 		case cClearHistory:
 			f.clearHistory()
 		case cCheckCache:
-			e.c <- f.checkCache(e.url, e.rangeInfo)
+			e.c <- f.checkCache(e.key, e.rangeInfo, e.header)
 		case cLookCache:
 			e.c <- f.lookCache(e.url)
 		case cListHistory:
 			e.c <- f.listHistory(e.url)
+		case cHistories:
+			e.c <- f.histories()
 		case cLookHistoryByID:
 			e.c <- f.lookHistoryByID(e.id)
+		case cFindHistory:
+			e.c <- f.findHistory(e.url, e.score)
 		case cSaveContentToCache:
 			e.c <- f.saveContentToCache(e.cache, e.save)
+		case cHistoryUsage:
+			e.c <- f.cache.Usage()
+		case cShrinkHistory:
+			e.c <- f.shrinkHistory(e.count, e.size)
 		case cLog:
 			f.log(e.s)
 		case cFormatHistory:
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/profile"
+
+	"io"
+	"time"
+)
+
+// network returns the network condition for a request, the url setting
+// first, then the profile's. Custom network in pack is resolved here.
+func (p *Proxy) network(up *policy.UrlPolicy, prof *profile.Profile) *policy.NetworkPolicy {
+	var n *policy.NetworkPolicy
+	if up != nil {
+		n = up.Network()
+	}
+
+	if n == nil && prof != nil {
+		n = prof.NetworkPolicy()
+	}
+
+	if n == nil || n.Off() {
+		return nil
+	}
+
+	if name := n.Pack(); len(name) > 0 {
+		return p.networkFromPack(name)
+	}
+
+	return n
+}
+
+// networkFromPack finds the first `network' command in pack, which should
+// not refer to another pack.
+func (p *Proxy) networkFromPack(name string) *policy.NetworkPolicy {
+	cmd := p.packs.Get(name)
+	if len(cmd) == 0 {
+		return nil
+	}
+
+	ps, _ := p.ParseCommand(cmd)
+	for _, s := range ps {
+		var n *policy.NetworkPolicy
+		switch s := s.(type) {
+		case *policy.NetworkPolicy:
+			n = s
+		case *policy.UrlPolicy:
+			n = s.Network()
+		}
+
+		if n != nil && !n.Off() && len(n.Pack()) == 0 {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func (p *Proxy) wrapNetworkWriter(n *policy.NetworkPolicy, w io.Writer, canSubPackage bool) io.Writer {
+	if n.Latency() {
+		w = newDelayWriter(n, w, p.r, canSubPackage)
+	}
+
+	if n.Down() > 0 {
+		w = newSpeedWriter(policy.MakeSpeedPolicy(n.Down()), w, canSubPackage)
+	}
+
+	return w
+}
+
+type speedReader struct {
+	speed float32
+	r     io.ReadCloser
+	start time.Time
+	bytes int64
+}
+
+func newSpeedReader(speed float32, r io.ReadCloser) io.ReadCloser {
+	return &speedReader{speed: speed, r: r}
+}
+
+func (s *speedReader) Read(p []byte) (int, error) {
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+
+	// read at most 1/4s of data once, so the speed would be smooth
+	max := int(s.speed / 4)
+	if max < 1 {
+		max = 1
+	}
+
+	if len(p) > max {
+		p = p[:max]
+	}
+
+	n, err := s.r.Read(p)
+	s.bytes += int64(n)
+	expect := time.Duration(float64(s.bytes) / float64(s.speed) * float64(time.Second))
+	if d := expect - time.Now().Sub(s.start); d > 0 {
+		<-time.NewTimer(d).C
+	}
+
+	return n, err
+}
+
+func (s *speedReader) Close() error {
+	return s.r.Close()
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+	"github.com/benbearchen/asuran/web/proxy/life"
+
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const offlineMissSource = "offline miss"
+
+// offlineScore scores recorded h for a request of method and fullUrl, 0 if
+// h can't answer it.
+func offlineScore(match string, h *cache.UrlHistory, method, fullUrl, rangeInfo string) int {
+	if h.Error != nil || h.ResponseCode <= 0 || h.ContentSource == offlineMissSource || h.Method != method {
+		return 0
+	} else if h.RangeInfo != rangeInfo && (len(h.RangeInfo) > 0 || h.ResponseCode != 200) {
+		return 0
+	}
+
+	switch match {
+	case policy.OfflineExact:
+		if h.Url == fullUrl {
+			return 1
+		}
+	case policy.OfflineIgnoreQuery:
+		if stripQuery(h.Url) == stripQuery(fullUrl) {
+			return 1
+		}
+	case policy.OfflineFuzzy:
+		return fuzzyUrlScore(h.Url, fullUrl)
+	}
+
+	return 0
+}
+
+func stripQuery(u string) string {
+	if q := strings.IndexByte(u, '?'); q >= 0 {
+		return u[:q]
+	}
+
+	return u
+}
+
+// fuzzyUrlScore matches urls of the same host and path, ignoring case of
+// host and the trailing "/", and scores 1 more for each same query pair.
+func fuzzyUrlScore(recorded, requested string) int {
+	a, err := url.Parse(recorded)
+	if err != nil {
+		return 0
+	}
+
+	b, err := url.Parse(requested)
+	if err != nil {
+		return 0
+	}
+
+	if !strings.EqualFold(a.Host, b.Host) || strings.TrimSuffix(a.Path, "/") != strings.TrimSuffix(b.Path, "/") {
+		return 0
+	}
+
+	score := 1
+	qa, qb := a.Query(), b.Query()
+	for k, vb := range qb {
+		if va, ok := qa[k]; ok && strings.Join(va, "&") == strings.Join(vb, "&") {
+			score++
+		}
+	}
+
+	return score
+}
+
+// offline answers r by the best record of the device, from history or disk
+// cache, or else with the status of op, and records the miss. The disk
+// cache is looked up by the key of cachePolicy exactly, regardless of the
+// match mode of op, as only the key is kept there.
+func (p *Proxy) offline(op *policy.OfflinePolicy, remoteIP, fullUrl string, cachePolicy *policy.CachePolicy, cacheKey string, w http.ResponseWriter, r *http.Request, rangeInfo string, f *life.Life, writeWrap io.Writer, faults *faultBody) {
+	start := time.Now()
+	var c *cache.UrlCache
+	source := ""
+	if f != nil {
+		h := f.FindHistory(fullUrl, func(h *cache.UrlHistory) int {
+			return offlineScore(op.Match(), h, r.Method, fullUrl, rangeInfo)
+		})
+
+		if h != nil {
+			c = h.UrlCache.Range(rangeInfo, r.Header)
+			source = "#" + strconv.FormatUint(uint64(h.ID), 10)
+		}
+	}
+
+	if c == nil && r.Method == "GET" {
+		if len(cacheKey) == 0 {
+			cacheKey = fullUrl
+			if cachePolicy != nil {
+				cacheKey = cachePolicy.Key(fullUrl, r.Method, r.Header, nil)
+			}
+		}
+
+		for _, scope := range []string{remoteIP, cache.SharedScope} {
+			if c = p.diskCache.Take(scope, cacheKey, rangeInfo, r.Header); c != nil {
+				source = "disk cache"
+				break
+			}
+		}
+	}
+
+	if c != nil && c.Error == nil {
+		if faults != nil {
+			faults.setTotal(len(c.Bytes))
+		}
+
+		c.Response(w, writeWrap)
+		if f != nil {
+			f.Log("offline " + fullUrl + " <- " + source)
+		}
+
+		return
+	}
+
+	status := op.Status()
+	w.WriteHeader(status)
+	if f != nil {
+		c := cache.NewUrlCache(fullUrl, r, nil, nil, offlineMissSource, nil, rangeInfo, start, time.Now(), nil)
+		c.ResponseCode = status
+		c.ResponseHeader = w.Header()
+		c.Policy = op.Command()
+		f.Log("offline " + fullUrl + " miss")
+		go p.saveContentToCache(fullUrl, f, c, false)
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"net/http"
+)
+
+func TestOfflineScore(t *testing.T) {
+	c := cache.NewCache()
+	save := func(method, url string, status int, rangeInfo string) uint32 {
+		return c.Save(&cache.UrlCache{Url: url, Method: method, ResponseCode: status, ResponseHeader: http.Header{}, RangeInfo: rangeInfo}, false)
+	}
+
+	a := save("GET", "http://g.cn/s?q=1&n=2", 200, "")
+	b := save("GET", "http://g.cn/s?q=2", 200, "")
+	save("POST", "http://g.cn/s?q=1&n=2", 200, "")
+	save("GET", "http://g.cn/s?q=1&n=2", 200, "bytes=0-1")
+	miss := &cache.UrlCache{Url: "http://g.cn/x", Method: "GET", ResponseCode: 504, ContentSource: offlineMissSource}
+	c.Save(miss, false)
+
+	check := func(match, url, rangeInfo string, id uint32) {
+		h := c.Find(func(h *cache.UrlHistory) int {
+			return offlineScore(match, h, "GET", url, rangeInfo)
+		})
+
+		if id == 0 && h != nil {
+			t.Errorf("offline %s %s should miss: #%d", match, url, h.ID)
+		} else if id != 0 && (h == nil || h.ID != id) {
+			t.Errorf("offline %s %s should be #%d: %v", match, url, id, h)
+		}
+	}
+
+	check(policy.OfflineExact, "http://g.cn/s?q=1&n=2", "", a)
+	check(policy.OfflineExact, "http://g.cn/s?q=1&n=2", "bytes=0-1", 4)
+	check(policy.OfflineExact, "http://g.cn/s?q=1&n=2", "bytes=-1", a)
+	check(policy.OfflineExact, "http://g.cn/s?n=2&q=1", "", 0)
+	check(policy.OfflineExact, "http://g.cn/x", "", 0)
+	check(policy.OfflineIgnoreQuery, "http://g.cn/s?q=3", "", b)
+	check(policy.OfflineIgnoreQuery, "http://g.cn/s/", "", 0)
+	check(policy.OfflineFuzzy, "http://G.cn/s/?n=2&q=1", "", a)
+	check(policy.OfflineFuzzy, "http://g.cn/s?q=2&n=3", "", b)
+	check(policy.OfflineFuzzy, "http://g.cn/s?x=1", "", b)
+	check(policy.OfflineFuzzy, "http://g.cn/t?q=1", "", 0)
+}
@@ -11,9 +11,11 @@ import (
 	"github.com/benbearchen/asuran/web/proxy/pack"
 	_ "github.com/benbearchen/asuran/web/proxy/plugin"
 	"github.com/benbearchen/asuran/web/proxy/plugin/api"
+	"github.com/benbearchen/asuran/web/proxy/session"
 	_ "github.com/benbearchen/asuran/web/proxy/tunnel"
 	tunnel "github.com/benbearchen/asuran/web/proxy/tunnel/api"
 
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -35,6 +37,10 @@ const (
 	ASURAN_PACK_HEADER   = "ASURAN_PACK"
 )
 
+const defaultDiskCacheSize = 1024 * 1024 * 1024
+const defaultHistorySize = 256 * 1024 * 1024
+const defaultTotalHistorySize = 1024 * 1024 * 1024
+
 type Proxy struct {
 	ver        string
 	webServers map[int]*httpd.Http
@@ -50,6 +56,11 @@ type Proxy struct {
 	disableDNS bool
 	packs      *pack.Dir
 	dirs       map[string]string
+	bandwidths map[string]*deviceBandwidth
+	diskCache  *cache.DiskCache
+	sessions   *session.Dir
+	replayers  map[string]*deviceReplayer
+	history    cache.HistoryLimit
 
 	lock sync.RWMutex
 	r    *rand.Rand
@@ -63,6 +74,13 @@ func NewProxy(ver string, dataDir string) *Proxy {
 	p.r = rand.New(rand.NewSource(time.Now().UnixNano()))
 	p.packs = pack.New(filepath.Join(dataDir, "packs"))
 	p.dirs = make(map[string]string)
+	p.bandwidths = make(map[string]*deviceBandwidth)
+	p.diskCache = cache.NewDiskCache(filepath.Join(dataDir, "cache"), defaultDiskCacheSize)
+	p.sessions = session.New(filepath.Join(dataDir, "sessions"))
+	p.replayers = make(map[string]*deviceReplayer)
+	p.history = cache.HistoryLimit{Size: defaultHistorySize}
+	p.lives.SetHistoryLimit(filepath.Join(dataDir, "history"), p.historyLimit)
+	p.lives.SetTotalHistoryLimit(0, defaultTotalHistorySize)
 	p.domain = "asu.run"
 
 	p.Bind(80, false)
@@ -128,6 +146,49 @@ func (p *Proxy) SetVisitDomain(domain string) {
 	p.domain = domain
 }
 
+// SetDiskCacheSize limits the size of disk cache, <= 0 for no limit.
+func (p *Proxy) SetDiskCacheSize(size int64) {
+	p.diskCache.SetMaxSize(size)
+}
+
+// SetHistoryLimit sets the limits of histories in memory for devices without
+// `history' setting, 0 for no limit.
+func (p *Proxy) SetHistoryLimit(count int, size int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.history = cache.HistoryLimit{Count: count, Size: size}
+}
+
+// SetTotalHistoryLimit sets the limits of histories in memory for all
+// devices, 0 for no limit.
+func (p *Proxy) SetTotalHistoryLimit(count int, size int64) {
+	p.lives.SetTotalHistoryLimit(count, size)
+}
+
+func (p *Proxy) historyLimit(ip string) cache.HistoryLimit {
+	if p.profileOp != nil {
+		if prof := p.profileOp.FindByIp(ip); prof != nil {
+			if hp := prof.HistoryPolicy(); hp != nil {
+				return cache.HistoryLimit{Count: hp.Count(), Size: hp.Size(), Evict: hp.Evict()}
+			}
+		}
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.history
+}
+
+func diskCacheScope(remoteIP string, cp *policy.CachePolicy) string {
+	if cp.Shared() {
+		return cache.SharedScope
+	}
+
+	return remoteIP
+}
+
 func (p *Proxy) Bind(port int, https bool) bool {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -245,6 +306,16 @@ func (p *Proxy) testUrl(
 	}
 }
 
+var proxyMethods = map[string]bool{
+	"GET":     true,
+	"POST":    true,
+	"HEAD":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+}
+
 func (p *Proxy) OnRequest(w http.ResponseWriter, r *http.Request) {
 	targetHost := httpd.RemoteHost(r.Host)
 	remoteIP := httpd.RemoteHost(r.RemoteAddr)
@@ -261,7 +332,7 @@ func (p *Proxy) OnRequest(w http.ResponseWriter, r *http.Request) {
 	//fmt.Printf("host: %s/%s, remote: %s/%s, url: %s\n", targetHost, r.Host, remoteIP, r.RemoteAddr, urlPath)
 	if r.Method == http.MethodConnect {
 		p.proxyHttps(remoteIP, w, r)
-	} else if r.Method != "GET" && r.Method != "POST" && r.Method != "HEAD" {
+	} else if !proxyMethods[r.Method] {
 		w.WriteHeader(502)
 		fmt.Fprintln(w, "unknown method", r.Method, "to", r.Host)
 	} else if p.isOtherTargetUrl(r.RequestURI) {
@@ -392,31 +463,45 @@ func (p *Proxy) proxyUrl(target string, w http.ResponseWriter, r *http.Request)
 
 func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r *http.Request, up *policy.UrlPolicy) {
 	needCache := false
+	var cachePolicy *policy.CachePolicy = nil
 
 	fullUrl := target
 	requestUrl := fullUrl
 	requestR := r
 	contentSource := ""
 
+	bypass := bypassPolicies(r)
 	var prof *profile.Profile
-	if !p.isSelfAddr(remoteIP) {
+	if !p.isSelfAddr(remoteIP) && !bypass {
 		prof = p.profileOp.Open(remoteIP)
 	}
 
+	if !bypass {
+		if b := p.bandwidth(remoteIP, prof); b != nil {
+			w = b.wrapResponseWriter(w)
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = b.wrapBody(r.Body)
+			}
+		}
+	}
+
 	rangeInfo := cache.CheckRange(r)
 	f := p.lives.Open(remoteIP)
 	var u *life.UrlState
+	var in *life.Incoming
 	if f != nil {
 		u = f.OpenUrl(fullUrl)
-		in := f.Incoming(fullUrl, "")
+		in = f.Incoming(fullUrl, "")
 		defer in.Done()
 	}
 
 	var writeWrap io.Writer = nil
 	forceChunked := false
 	forceRecvFirst := false
+	var editor *contentEditor = nil
+	var faults *faultBody = nil
 
-	if up == nil {
+	if up == nil && !bypass {
 		if cmd := r.Header.Get(ASURAN_POLICY_HEADER); len(cmd) > 0 {
 			p, err := policy.Factory("url " + cmd)
 			if err != nil {
@@ -447,7 +532,27 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 		return
 	}
 
+	upload := newUploadReader(up, r)
+	if upload != nil {
+		r.Body = upload
+	}
+
+	network := p.network(up, prof)
+	if network != nil && network.Reset(p.r) {
+		if f != nil {
+			f.Log("proxy " + fullUrl + " network " + network.Preset() + " reset")
+		}
+
+		net.ResetResponse(w)
+		return
+	}
+
 	if up != nil {
+		if up.Hang() != nil {
+			p.hang(fullUrl, w, r, rangeInfo, up, f, in)
+			return
+		}
+
 		delay := up.DelayPolicy()
 		if delay != nil {
 			//fmt.Println("url delay: " + delay.String())
@@ -482,14 +587,23 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 			}
 		}
 
-		if s := up.Status(); s != nil {
-			status := s.StatusCode()
-			if status == 0 {
-				status = 502
+		if cp := up.Cors(); cp != nil && cp.Allow() && isCorsPreflight(r) {
+			start := time.Now()
+			corsPreflight(w, r, cp)
+			if f != nil {
+				c := cache.NewUrlCache(fullUrl, r, nil, nil, "cors preflight", nil, rangeInfo, start, time.Now(), nil)
+				c.ResponseCode = 204
+				c.ResponseHeader = w.Header()
+				c.Policy = up.Command()
+				f.Log("proxy " + fullUrl + " cors preflight")
+				go p.saveContentToCache(fullUrl, f, c, false)
 			}
 
-			w.WriteHeader(status)
-			f.Log("proxy " + fullUrl + " status " + strconv.Itoa(status))
+			return
+		}
+
+		if s := up.Status(); s != nil && !statusWithContent(up) {
+			p.replyStatus(fullUrl, w, r, up, f)
 			return
 		}
 
@@ -503,6 +617,7 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 			switch act := act.(type) {
 			case *policy.CachePolicy:
 				needCache = true
+				cachePolicy = act
 			case *policy.MapPolicy:
 				requestUrl = act.URL(requestUrl)
 				requestR = nil
@@ -512,13 +627,21 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 				http.Redirect(w, r, requestUrl, 302)
 				f.Log("proxy " + fullUrl + " redirect " + requestUrl)
 				return
-			case *policy.RewritePolicy, *policy.RestorePolicy, *policy.TcpwritePolicy:
-				if p.rewriteUrl(fullUrl, w, r, rangeInfo, prof, f, act, speed, chunked, bodyDelay, up.ContentType(), up.ResponseHeaders()) {
+			case *policy.RewritePolicy, *policy.RestorePolicy, *policy.TcpwritePolicy, *policy.TcpscriptPolicy, *policy.MalformedPolicy:
+				if p.rewriteUrl(fullUrl, up, w, r, rangeInfo, prof, f, act, speed, chunked, bodyDelay, network) {
+					return
+				} else if up.Status() != nil {
+					// status still answers without the content
+					p.replyStatus(fullUrl, w, r, up, f)
 					return
 				}
 			}
 		}
 
+		if r.Method != "HEAD" {
+			editor = newContentEditor(up)
+		}
+
 		if chunked != nil {
 			chunkedOp := chunked.Option()
 			if chunkedOp != policy.ChunkedDefault && chunkedOp != policy.ChunkedOff {
@@ -530,6 +653,19 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 			}
 		}
 
+		faults = newFaultBody(w.Header())
+		if fw := p.wrapFaultWriter(up, w, faults); fw != io.Writer(w) {
+			writeWrap = fw
+		}
+
+		if network != nil {
+			if writeWrap == nil {
+				writeWrap = w
+			}
+
+			writeWrap = p.wrapNetworkWriter(network, writeWrap, !forceChunked)
+		}
+
 		if bodyDelay != nil {
 			switch bodyDelay.(type) {
 			case *policy.DelayPolicy, *policy.TimeoutPolicy:
@@ -560,9 +696,37 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 		}
 	}
 
-	if needCache && r.Method == "GET" && f != nil {
-		c := f.CheckCache(fullUrl, rangeInfo)
+	cacheKey := ""
+	var cacheHeader http.Header
+	// other methods are cached only if they are told apart by key
+	if needCache && (f == nil || (r.Method != "GET" && !cachePolicy.AnyMethod())) {
+		needCache = false
+	}
+
+	if needCache {
+		var body []byte
+		if cachePolicy.NeedBody() && r.Body != nil {
+			body = readPostBody(r)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		cacheKey = cachePolicy.Key(fullUrl, r.Method, r.Header, body)
+		cacheHeader = r.Header.Clone()
+
+		var c *cache.UrlCache
+		if cachePolicy.Disk() {
+			c = p.diskCache.Take(diskCacheScope(remoteIP, cachePolicy), cacheKey, rangeInfo, cacheHeader)
+		}
+
+		if c == nil {
+			c = f.CheckCache(cacheKey, rangeInfo, cacheHeader)
+		}
+
 		if c != nil && c.Error == nil {
+			if faults != nil {
+				faults.setTotal(len(c.Bytes))
+			}
+
 			c.Response(w, writeWrap)
 			extraInfo := ""
 			if len(rangeInfo) > 0 {
@@ -574,13 +738,23 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 		}
 	}
 
+	if prof != nil {
+		if rp := prof.ReplayPolicy(); rp != nil && p.replay(rp, remoteIP, fullUrl, w, r, rangeInfo, f, writeWrap, faults) {
+			return
+		}
+
+		if op := prof.OfflinePolicy(); op != nil {
+			p.offline(op, remoteIP, fullUrl, cachePolicy, cacheKey, w, r, rangeInfo, f, writeWrap, faults)
+			return
+		}
+	}
+
 	dont302 := true
-	settingContentType := "default"
 	var hostPolicy *policy.HostPolicy
-	var headersPolicy *policy.HeadersPolicy
+	var originalPostBody []byte
+	var requestWarnings []string
 	if up != nil {
 		dont302 = up.Dont302()
-		settingContentType = up.ContentType()
 		if requestR != nil {
 			if up.Disable304() {
 				p.disable304FromHeader(requestR.Header)
@@ -590,16 +764,41 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 			if hp != nil {
 				hp.Apply(requestR.Header)
 			}
+
+			if cp := up.Cookies(); cp != nil {
+				cp.ApplyRequest(requestR.Header)
+			}
+
+			if editor != nil && (editor.encoding != nil || len(requestR.Header.Get("Accept-Encoding")) > 0) {
+				requestR.Header.Set("Accept-Encoding", editor.acceptEncoding())
+			}
+
+			if rb := up.RequestBody(); rb != nil && requestR.Method != "GET" && requestR.Method != "HEAD" {
+				originalPostBody, requestWarnings = editRequestBody(requestR, rb)
+			}
 		}
 
 		hostPolicy = up.Host()
-		headersPolicy = up.ResponseHeaders()
+	}
+
+	if network != nil && network.Up() > 0 && requestR != nil && requestR.Body != nil {
+		requestR.Body = newSpeedReader(network.Up(), requestR.Body)
+	}
+
+	// bypass skips domain settings of the device too
+	dialClient := remoteIP
+	if bypass {
+		dialClient = ""
 	}
 
 	httpStart := time.Now()
-	resp, postBody, redirection, err := net.NewHttp(requestUrl, requestR, p.parseDomainAsDial(requestUrl, remoteIP, hostPolicy), dont302)
+	resp, postBody, redirection, err := net.NewHttp(requestUrl, requestR, p.parseDomainAsDial(requestUrl, dialClient, hostPolicy), dont302)
 	if err != nil {
-		c := cache.NewUrlCache(fullUrl, r, nil, nil, contentSource, nil, rangeInfo, httpStart, time.Now(), err)
+		c := cache.NewUrlCache(fullUrl, r, postBody, nil, contentSource, nil, rangeInfo, httpStart, time.Now(), err)
+		c.Policy = urlPolicyCommand(up)
+		c.OriginalPostBody = originalPostBody
+		c.Warnings = requestWarnings
+		upload.record(c)
 		if f != nil {
 			go p.saveContentToCache(fullUrl, f, c, false)
 		}
@@ -612,63 +811,160 @@ func (p *Proxy) remoteProxyUrl(remoteIP, target string, w http.ResponseWriter, r
 		}
 	} else {
 		defer resp.Close()
-		p.procHeader(resp.Header(), settingContentType, headersPolicy)
-		content, err := resp.ProxyReturn(w, writeWrap, forceRecvFirst, forceChunked)
+		p.procHeader(resp.Header(), r, up)
+		var edit func(http.Header, []byte) ([]byte, error) = nil
+		if editor != nil {
+			edit = editor.Edit
+		}
+
+		if needFaultTotal(up) {
+			edit = faults.recordEdit(edit)
+		}
+
+		content, err := resp.ProxyReturn(w, writeWrap, forceRecvFirst, forceChunked, edit)
 		httpEnd := time.Now()
 		c := cache.NewUrlCache(fullUrl, r, postBody, resp, contentSource, content, rangeInfo, httpStart, httpEnd, err)
+		c.Policy = urlPolicyCommand(up)
+		c.OriginalPostBody = originalPostBody
+		c.Warnings = requestWarnings
+		c.Key = cacheKey
+		c.KeyHeader = cacheHeader
+		upload.record(c)
+		if editor != nil {
+			c.Warnings = append(c.Warnings, editor.warnings...)
+		}
+
 		if f != nil {
 			go p.saveContentToCache(fullUrl, f, c, needCache)
 		}
+
+		if needCache && cachePolicy.Disk() && err == nil {
+			go p.diskCache.Save(diskCacheScope(remoteIP, cachePolicy), c, cachePolicy.TTL())
+		}
+
+		if prof != nil && err == nil {
+			if rp := prof.RecordPolicy(); rp != nil {
+				// recorded in order of requests, for ordered replay
+				p.record(rp, f, c)
+			}
+		}
 	}
 }
 
-func (p *Proxy) rewriteUrl(target string, w http.ResponseWriter, r *http.Request, rangeInfo string, prof *profile.Profile, f *life.Life, act policy.Policy, speed *policy.SpeedPolicy, chunked *policy.ChunkedPolicy, bodyDelay policy.Policy, contentType string, hp *policy.HeadersPolicy) bool {
+func (p *Proxy) rewriteUrl(target string, up *policy.UrlPolicy, w http.ResponseWriter, r *http.Request, rangeInfo string, prof *profile.Profile, f *life.Life, act policy.Policy, speed *policy.SpeedPolicy, chunked *policy.ChunkedPolicy, bodyDelay policy.Policy, network *policy.NetworkPolicy) bool {
 	var content []byte = nil
+	var postBody []byte = nil
+	var err error = nil
 	contentSource := ""
 	istcp := false
+	var script []policy.TcpStep = nil
 	switch act := act.(type) {
 	case *policy.RewritePolicy:
-		u, err := url.QueryUnescape(act.Value())
-		if err != nil {
-			return false
-		}
-
-		content = []byte(u)
 		contentSource = "rewrite"
+		if act.Template() {
+			postBody = readPostBody(r)
+			content, err = act.Execute(newTemplateData(target, up.Target(), r, postBody))
+		} else {
+			content, err = act.Content()
+			if err != nil {
+				return false
+			}
+		}
 	case *policy.TcpwritePolicy:
 		istcp = true
-		u, err := url.QueryUnescape(act.Value())
-		if err != nil {
-			return false
+		contentSource = "tcpwrite"
+		if act.Template() {
+			postBody = readPostBody(r)
+			content, err = act.Execute(newTemplateData(target, up.Target(), r, postBody))
+		} else {
+			content, err = act.Content()
+			if err != nil {
+				return false
+			}
 		}
+	case *policy.TcpscriptPolicy:
+		istcp = true
+		contentSource = "tcpscript"
+		if act.Store() {
+			stored := prof.Restore(act.Value())
+			if stored == nil {
+				return false
+			}
 
-		content = []byte(u)
-		contentSource = "tcpwrite"
+			contentSource = "tcpscript " + act.Value()
+			script, err = policy.ParseTcpScript(string(stored))
+		} else {
+			script, err = act.Steps()
+		}
+	case *policy.MalformedPolicy:
+		istcp = true
+		contentSource = "malformed " + act.Kind()
+		content = act.Response([]byte("malformed response from asuran: " + act.Kind() + "\n"))
 	case *policy.RestorePolicy:
 		content = prof.Restore(act.Value())
 		if content == nil {
 			return false
 		}
+
+		if act.Template() {
+			contentSource = "restore template"
+			postBody = readPostBody(r)
+			content, err = act.Execute(content, newTemplateData(target, up.Target(), r, postBody))
+		}
 	default:
 		return false
 	}
 
-	if len(rangeInfo) > 0 {
-		c, cr, err := cache.MakeRange(rangeInfo, content)
+	if err != nil {
+		what := "template"
+		if _, ok := act.(*policy.TcpscriptPolicy); ok {
+			what = "tcpscript"
+		}
+
+		start := time.Now()
+		http.Error(w, what+" error: "+err.Error(), 500)
+		c := cache.NewUrlCache(target, r, postBody, nil, contentSource, nil, rangeInfo, start, time.Now(), err)
+		c.Policy = up.Command()
+		if f != nil {
+			f.Log("proxy " + target + " " + what + " error: " + err.Error())
+			p.saveContentToCache(target, f, c, false)
+		}
+
+		return true
+	}
+
+	switch act.(type) {
+	case *policy.TcpwritePolicy, *policy.TcpscriptPolicy, *policy.MalformedPolicy:
+	default:
+		p.procHeader(w.Header(), r, up)
+	}
+
+	// ranges after procHeader, for If-Range may match an ETag of settings
+	status := 200
+	if s := up.Status(); s != nil && s.StatusCode() > 0 {
+		status = s.StatusCode()
+	}
+
+	if status == 200 && len(rangeInfo) > 0 && !istcp && cache.MatchIfRange(r.Header, w.Header()) {
+		c, err := cache.MakeRange(rangeInfo, content, w.Header())
 		if err != nil {
+			start := time.Now()
 			w.WriteHeader(416)
 			fmt.Fprintln(w, "error:", err)
+			c := cache.NewUrlCache(target, r, postBody, nil, contentSource, nil, rangeInfo, start, time.Now(), err)
+			c.Policy = up.Command()
+			c.ResponseCode = 416
+			c.ResponseHeader = w.Header()
+			if f != nil {
+				f.Log("proxy " + target + " range error: " + err.Error())
+				p.saveContentToCache(target, f, c, false)
+			}
+
 			return true
-		} else {
-			w.Header()["Content-Range"] = []string{"bytes " + cr}
-			w.Header()["Content-Length"] = []string{strconv.Itoa(len(c))}
-			w.WriteHeader(206)
-			content = c
 		}
-	}
 
-	if _, ok := act.(*policy.TcpwritePolicy); !ok {
-		p.procHeader(w.Header(), contentType, hp)
+		status = 206
+		content = c
 	}
 
 	forceChunked := false
@@ -680,11 +976,39 @@ func (p *Proxy) rewriteUrl(target string, w http.ResponseWriter, r *http.Request
 	}
 
 	var writeWrapper func(w io.Writer) io.Writer = nil
+	faults := newFaultBody(w.Header())
+	if up.Cut() != nil || up.Stall() != nil || up.Corrupt() != nil {
+		writeWrapper = func(w io.Writer) io.Writer {
+			return p.wrapFaultWriter(up, w, faults)
+		}
+	}
+
+	if network != nil {
+		canSubPackage := !forceChunked
+		if writeWrapper != nil {
+			wrap := writeWrapper
+			writeWrapper = func(w io.Writer) io.Writer {
+				return p.wrapNetworkWriter(network, wrap(w), canSubPackage)
+			}
+		} else {
+			writeWrapper = func(w io.Writer) io.Writer {
+				return p.wrapNetworkWriter(network, w, canSubPackage)
+			}
+		}
+	}
+
 	if bodyDelay != nil {
 		switch bodyDelay.(type) {
 		case *policy.DelayPolicy, *policy.TimeoutPolicy:
-			writeWrapper = func(w io.Writer) io.Writer {
-				return newDelayWriter(bodyDelay, w, p.r, true)
+			if writeWrapper != nil {
+				wrap := writeWrapper
+				writeWrapper = func(w io.Writer) io.Writer {
+					return newDelayWriter(bodyDelay, wrap(w), p.r, true)
+				}
+			} else {
+				writeWrapper = func(w io.Writer) io.Writer {
+					return newDelayWriter(bodyDelay, w, p.r, true)
+				}
 			}
 		}
 	}
@@ -716,28 +1040,46 @@ func (p *Proxy) rewriteUrl(target string, w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	// history keeps content as it is, while body may be encoded
+	body := content
+	if e := up.Encoding(); e != nil && !istcp && status != 206 && len(content) > 0 && e.Encoding() != policy.EncodingIdentity {
+		if b, err := net.EncodeContent(e.Encoding(), content); err == nil {
+			w.Header().Set("Content-Encoding", e.Encoding())
+			body = b
+		}
+	}
+
 	start := time.Now()
-	if istcp {
+	total := len(body)
+	if script != nil {
+		total = tcpScriptSize(script)
+	}
+
+	faults.setTotal(total)
+	if script != nil {
+		content, err = runTcpScript(w, writeWrapper, script)
+	} else if istcp {
 		net.TcpWriteHttp(w, writeWrapper, content)
 	} else {
 		if !forceChunked {
 			// set the Content-Length, then chunked would be disabled
-			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 		}
 
-		w.WriteHeader(200)
+		w.WriteHeader(status)
 		if writeWrapper != nil {
-			writeWrapper(w).Write(content)
+			writeWrapper(w).Write(body)
 		} else {
-			w.Write(content)
+			w.Write(body)
 		}
 	}
 
-	c := cache.NewUrlCache(target, r, nil, nil, contentSource, content, rangeInfo, start, time.Now(), nil)
+	c := cache.NewUrlCache(target, r, postBody, nil, contentSource, content, rangeInfo, start, time.Now(), err)
+	c.Policy = up.Command()
 	if istcp {
 		c.ResponseCode = 599
 	} else {
-		c.ResponseCode = 200
+		c.ResponseCode = status
 	}
 	if f != nil {
 		p.saveContentToCache(target, f, c, false)
@@ -746,6 +1088,79 @@ func (p *Proxy) rewriteUrl(target string, w http.ResponseWriter, r *http.Request
 	return true
 }
 
+// replyStatus answers the status of up with an empty body.
+func (p *Proxy) replyStatus(fullUrl string, w http.ResponseWriter, r *http.Request, up *policy.UrlPolicy, f *life.Life) {
+	status := up.Status().StatusCode()
+	if status == 0 {
+		status = 502
+	}
+
+	p.procHeader(w.Header(), r, up)
+
+	w.WriteHeader(status)
+	f.Log("proxy " + fullUrl + " status " + strconv.Itoa(status))
+}
+
+// statusWithContent tells whether status answers with the content of
+// rewrite or restore, instead of an empty body.
+func statusWithContent(up *policy.UrlPolicy) bool {
+	switch up.ContentPolicy().(type) {
+	case *policy.RewritePolicy, *policy.RestorePolicy:
+		return true
+	default:
+		return false
+	}
+}
+
+func urlPolicyCommand(up *policy.UrlPolicy) string {
+	if up == nil || len(up.Policy()) == 0 {
+		return ""
+	}
+
+	return up.Command()
+}
+
+func readPostBody(r *http.Request) []byte {
+	if r.Method == "GET" || r.Method == "HEAD" || r.Body == nil {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+func newTemplateData(target, pattern string, r *http.Request, body []byte) *policy.TemplateData {
+	d := &policy.TemplateData{
+		Method:   r.Method,
+		URL:      target,
+		Captures: profile.NewUrlPattern(pattern).Captures(target),
+		Header:   r.Header,
+		Body:     string(body),
+		Now:      time.Now(),
+	}
+
+	if u, err := url.Parse(target); err == nil {
+		d.Scheme = u.Scheme
+		d.Host = u.Host
+		d.Path = u.Path
+		d.RawQuery = u.RawQuery
+		d.Query = u.Query()
+	}
+
+	if len(body) > 0 {
+		var v interface{}
+		if json.Unmarshal(body, &v) == nil {
+			d.JSON = v
+		}
+	}
+
+	return d
+}
+
 func (p *Proxy) initDevice(w io.Writer, ip string) {
 	if p.profileOp != nil {
 		p.profileOp.Open(ip)
@@ -894,6 +1309,8 @@ func (p *Proxy) ownProfile(ownerIP, page string, w http.ResponseWriter, r *http.
 		if f := p.lives.Visit(profileIP); f != nil {
 			if len(pages) >= 4 && pages[3] == "watch.json" {
 				p.watchHistory(w, r, profileIP, f)
+			} else if len(pages) >= 4 && pages[3] == "search.json" {
+				p.searchHistory(w, r, f)
 			} else if len(pages) >= 4 && pages[3] == "clear" {
 				f.ClearHistory()
 				fmt.Fprintf(w, "cleared")
@@ -904,6 +1321,24 @@ func (p *Proxy) ownProfile(ownerIP, page string, w http.ResponseWriter, r *http.
 			fmt.Fprintln(w, profileIP+" 不存在")
 		}
 		return
+	} else if op == "history.har" {
+		if f := p.lives.OpenExists(profileIP); f != nil {
+			p.exportHar(w, r, profileIP, f)
+		} else {
+			w.WriteHeader(404)
+			fmt.Fprintln(w, profileIP+" 不存在")
+		}
+		return
+	} else if op == "har" {
+		if r.Method != "POST" {
+			p.writeHarImport(w, harImportData{profileIP, nil, ""})
+		} else if !canOperate {
+			w.WriteHeader(403)
+			fmt.Fprintln(w, "无权操作")
+		} else {
+			p.importHar(w, r, profileIP, f, len(pages) >= 4 && pages[3] == "import")
+		}
+		return
 	} else if op == "in.json" {
 		if f := p.lives.OpenExists(profileIP); f != nil {
 			p.watchIncoming(w, r, profileIP, f)
@@ -915,7 +1350,10 @@ func (p *Proxy) ownProfile(ownerIP, page string, w http.ResponseWriter, r *http.
 	} else if op == "look" || op == "list" || op == "detail" {
 		if len(pages) >= 4 {
 			id, err := strconv.ParseUint(pages[3], 10, 32)
-			if err == nil {
+			if err == nil && op == "look" && len(pages) >= 5 && pages[4] == "resend" {
+				p.resendHistory(w, r, profileIP, uint32(id), canOperate)
+				return
+			} else if err == nil {
 				p.lookHistoryByID(w, profileIP, uint32(id), op)
 				return
 			}
@@ -1270,10 +1708,12 @@ func (p *Proxy) NewProxyHostOperator() profile.ProxyHostOperator {
 	return &proxyHostOperator{p}
 }
 
+// parseDomainAsDial dials by hostPolicy, or else by domain settings of
+// client, which are skipped if client is empty.
 func (p *Proxy) parseDomainAsDial(target, client string, hostPolicy *policy.HostPolicy) func(network, addr string) (gonet.Conn, error) {
 	address := ""
 	if hostPolicy == nil {
-		if p.domainOp == nil {
+		if p.domainOp == nil || len(client) == 0 {
 			return nil
 		}
 
@@ -1411,7 +1851,12 @@ func (p *Proxy) isOtherTargetUrl(requestUrl string) bool {
 	}
 }
 
-func (p *Proxy) procHeader(header http.Header, settingContentType string, hp *policy.HeadersPolicy) {
+func (p *Proxy) procHeader(header http.Header, r *http.Request, up *policy.UrlPolicy) {
+	if up == nil {
+		return
+	}
+
+	settingContentType := up.ContentType()
 	switch settingContentType {
 	case "default":
 	case "remove":
@@ -1423,9 +1868,17 @@ func (p *Proxy) procHeader(header http.Header, settingContentType string, hp *po
 		header["Content-Type"] = []string{settingContentType}
 	}
 
-	if hp != nil {
+	if hp := up.ResponseHeaders(); hp != nil {
 		hp.Apply(header)
 	}
+
+	if cp := up.Cookies(); cp != nil {
+		cp.ApplyResponse(header)
+	}
+
+	if cp := up.Cors(); cp != nil {
+		applyCors(header, r, cp)
+	}
 }
 
 func (p *Proxy) disable304FromHeader(header http.Header) {
@@ -1481,6 +1934,10 @@ func (p *Proxy) proxyWebsocket(client string, w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if b := p.bandwidth(client, nil); b != nil {
+		downConn = b.wrapConn(downConn)
+	}
+
 	net.PipeConn(upConn, downConn)
 }
 
@@ -1530,6 +1987,10 @@ func (p *Proxy) proxyHttps(client string, w http.ResponseWriter, r *http.Request
 	down.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n")
 	down.Flush()
 
+	if b := p.bandwidth(client, nil); b != nil {
+		downConn = b.wrapConn(downConn)
+	}
+
 	net.PipeConn(upConn, downConn)
 }
 
@@ -1721,6 +2182,7 @@ func (p *Proxy) plugin(profileIP string, up *policy.UrlPolicy, target string, w
 		go func() {
 			c := cache.NewUrlCache(target, r, postBody, nil, "plugin "+pluginPolicy.Name(), content, "", start, time.Now(), err)
 			c.ResponseCode = statusCode
+			c.Policy = up.Command()
 
 			id := f.SaveContentToCache(c, false)
 
@@ -1830,6 +2292,9 @@ func mapIncoming(in *life.Incoming) map[string]interface{} {
 	m["id"] = in.ID()
 	m["t"] = fmt.Sprintf("%016x", in.T().UnixNano())
 	m["key"] = in.Key()
+	if act := in.Act(); len(act) > 0 {
+		m["act"] = act
+	}
 	m["start"] = strconv.FormatInt(in.Start().UnixNano()/1000000, 10)
 	if !in.End().IsZero() {
 		m["end"] = strconv.FormatInt(in.End().UnixNano()/1000000, 10)
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+	"github.com/benbearchen/asuran/web/proxy/life"
+	"github.com/benbearchen/asuran/web/proxy/session"
+
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const replayMissSource = "replay miss"
+
+type deviceReplayer struct {
+	policy   *policy.ReplayPolicy
+	replayer *session.Replayer
+}
+
+// replayer returns the replayer of device ip for rp, which is made again
+// once `replay' is set again, so that ordered replay starts over.
+func (p *Proxy) replayer(ip string, rp *policy.ReplayPolicy) (*session.Replayer, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if r, ok := p.replayers[ip]; ok && r.policy == rp {
+		return r.replayer, nil
+	}
+
+	s, err := p.sessions.Open(rp.Session())
+	if err != nil {
+		return nil, err
+	}
+
+	r := session.NewReplayer(s, rp.Ordered(), rp.Body())
+	p.replayers[ip] = &deviceReplayer{rp, r}
+	return r, nil
+}
+
+// replay answers r by the recorded exchange of the session, and returns
+// false to proxy r as usual if none matches and it isn't strict.
+func (p *Proxy) replay(rp *policy.ReplayPolicy, remoteIP, fullUrl string, w http.ResponseWriter, r *http.Request, rangeInfo string, f *life.Life, writeWrap io.Writer, faults *faultBody) bool {
+	start := time.Now()
+	replayer, err := p.replayer(remoteIP, rp)
+	if err != nil {
+		if f != nil {
+			f.Log("replay " + fullUrl + " failed: " + err.Error())
+		}
+
+		if !rp.Strict() {
+			return false
+		}
+
+		http.Error(w, err.Error(), 502)
+		return true
+	}
+
+	var body []byte
+	if rp.Body() {
+		body = readPostBody(r)
+		if body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+	}
+
+	var c *cache.UrlCache
+	if e := replayer.Match(r.Method, fullUrl, body); e != nil {
+		c = e.Range(rangeInfo, r.Header)
+	}
+
+	if c != nil {
+		if faults != nil {
+			faults.setTotal(len(c.Bytes))
+		}
+
+		c.Response(w, writeWrap)
+		if f != nil {
+			f.Log("replay " + fullUrl + " <- " + rp.Session())
+		}
+
+		return true
+	}
+
+	if !rp.Strict() {
+		return false
+	}
+
+	http.Error(w, "no exchange in session "+rp.Session(), 502)
+	if f != nil {
+		c := cache.NewUrlCache(fullUrl, r, body, nil, replayMissSource, nil, rangeInfo, start, time.Now(), nil)
+		c.ResponseCode = 502
+		c.ResponseHeader = w.Header()
+		c.Policy = rp.Command()
+		f.Log("replay " + fullUrl + " miss")
+		go p.saveContentToCache(fullUrl, f, c, false)
+	}
+
+	return true
+}
+
+// record saves c to the session of device, only exchanges from upstream.
+func (p *Proxy) record(rp *policy.RecordPolicy, f *life.Life, c *cache.UrlCache) {
+	if err := p.sessions.Record(rp.Session(), c); err != nil && f != nil {
+		f.Log("record " + c.Url + " failed: " + err.Error())
+	}
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type bypassKey struct{}
+
+// bypassPolicies tells whether r is resent without policies of the device.
+func bypassPolicies(r *http.Request) bool {
+	b, _ := r.Context().Value(bypassKey{}).(bool)
+	return b
+}
+
+type resendData struct {
+	IP     string
+	ID     uint32
+	Method string
+	Url    string
+	Header string
+	Body   string
+	Error  string
+}
+
+func newResendData(profileIP string, h *cache.UrlHistory) resendData {
+	keys := make([]string, 0, len(h.RequestHeader))
+	for k := range h.RequestHeader {
+		if k != "Content-Length" {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range h.RequestHeader[k] {
+			lines = append(lines, k+": "+v)
+		}
+	}
+
+	return resendData{profileIP, h.ID, h.Method, h.Url, strings.Join(lines, "\n"), string(h.RequestBody()), ""}
+}
+
+func parseResendHeader(s string) (http.Header, error) {
+	header := make(http.Header)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		c := strings.IndexByte(line, ':')
+		if c <= 0 {
+			return nil, fmt.Errorf("wrong header: %s", line)
+		}
+
+		header.Add(strings.TrimSpace(line[:c]), strings.TrimSpace(line[c+1:]))
+	}
+
+	return header, nil
+}
+
+// newResendRequest makes the request edited by the form from h.
+func newResendRequest(r *http.Request, h *cache.UrlHistory) (*http.Request, error) {
+	method := strings.ToUpper(strings.TrimSpace(r.FormValue("method")))
+	if len(method) == 0 {
+		method = "GET"
+	}
+
+	target := strings.TrimSpace(r.FormValue("url"))
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" || len(u.Host) == 0 {
+		return nil, fmt.Errorf("wrong url: %s", target)
+	}
+
+	header, err := parseResendHeader(r.FormValue("header"))
+	if err != nil {
+		return nil, err
+	}
+
+	// textarea posts lines by CRLF
+	body := r.FormValue("body")
+	if !bytes.ContainsRune(h.RequestBody(), '\r') {
+		body = strings.Replace(body, "\r\n", "\n", -1)
+	}
+
+	var b io.Reader
+	if len(body) > 0 {
+		b = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, target, b)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = header
+	req.RemoteAddr = r.RemoteAddr
+	req = cache.WithResendOf(req, h.ID)
+	if len(r.FormValue("bypass")) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), bypassKey{}, true))
+	}
+
+	return req, nil
+}
+
+// resendHistory shows the editor of history id, or sends the edited request
+// as from the device, answering what it gets.
+func (p *Proxy) resendHistory(w http.ResponseWriter, r *http.Request, profileIP string, id uint32, canOperate bool) {
+	f := p.lives.Visit(profileIP)
+	if f == nil {
+		w.WriteHeader(404)
+		fmt.Fprintln(w, profileIP+" 不存在")
+		return
+	}
+
+	h := f.LookHistoryByID(id)
+	if h == nil {
+		w.WriteHeader(404)
+		fmt.Fprintf(w, "history %d not exist", id)
+		return
+	}
+
+	if r.Method != "POST" {
+		p.writeResend(w, newResendData(profileIP, h))
+		return
+	} else if !canOperate {
+		w.WriteHeader(403)
+		fmt.Fprintln(w, "无权操作")
+		return
+	}
+
+	req, err := newResendRequest(r, h)
+	if err != nil {
+		data := resendData{profileIP, id, r.FormValue("method"), r.FormValue("url"), r.FormValue("header"), r.FormValue("body"), err.Error()}
+		w.WriteHeader(400)
+		p.writeResend(w, data)
+		return
+	}
+
+	p.remoteProxyUrl(profileIP, req.URL.String(), w, req, nil)
+}
+
+func (p *Proxy) writeResend(w http.ResponseWriter, data resendData) {
+	t, err := template.ParseFiles("template/resend.tmpl")
+	err = t.Execute(w, data)
+	if err != nil {
+		fmt.Fprintln(w, "内部错误：", err)
+	}
+}
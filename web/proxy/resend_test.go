@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewResendRequest(t *testing.T) {
+	h := &cache.UrlHistory{UrlCache: cache.UrlCache{Method: "POST", Url: "http://a.cn/x", PostBody: []byte("a\nb")}, ID: 3}
+	form := func(v url.Values) *http.Request {
+		r, _ := http.NewRequest("POST", "http://localhost/profile/1.2.3.4/look/3/resend", strings.NewReader(v.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	req, err := newResendRequest(form(url.Values{
+		"method": {"put"},
+		"url":    {"http://a.cn/y?q=1"},
+		"header": {"X-A: 1\r\n\r\nX-A:2\r\nCookie: c=d"},
+		"body":   {"a\r\nb2"},
+	}), h)
+	if err != nil {
+		t.Fatalf("newResendRequest failed: %v", err)
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Method != "PUT" || req.URL.String() != "http://a.cn/y?q=1" || string(body) != "a\nb2" {
+		t.Errorf("request wrong: %s %s %q", req.Method, req.URL, body)
+	} else if v := req.Header["X-A"]; len(v) != 2 || v[0] != "1" || v[1] != "2" || req.Header.Get("Cookie") != "c=d" {
+		t.Errorf("header wrong: %v", req.Header)
+	} else if cache.ResendOf(req) != 3 || bypassPolicies(req) {
+		t.Errorf("resend of %d, bypass %v", cache.ResendOf(req), bypassPolicies(req))
+	}
+
+	c := cache.NewUrlCache(req.URL.String(), req, body, nil, "", nil, "", time.Now(), time.Now(), nil)
+	if c.ResendOf != 3 {
+		t.Errorf("history should be resent of 3: %d", c.ResendOf)
+	}
+
+	req, err = newResendRequest(form(url.Values{"url": {"https://a.cn/"}, "bypass": {"1"}}), h)
+	if err != nil || req.Method != "GET" || req.Body != nil || !bypassPolicies(req) {
+		t.Errorf("bypass request wrong: %v", err)
+	}
+
+	for _, v := range []url.Values{
+		{"url": {"a.cn/x"}},
+		{"url": {"ftp://a.cn/x"}},
+		{"url": {"http://a.cn/"}, "header": {"no colon"}},
+	} {
+		if _, err := newResendRequest(form(v), h); err == nil {
+			t.Errorf("newResendRequest(%v) should fail", v)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+	"github.com/benbearchen/asuran/web/proxy/life"
+
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type jsonHistory struct {
+	ID          uint32  `json:"id"`
+	Time        string  `json:"time"`
+	Method      string  `json:"method"`
+	Url         string  `json:"url"`
+	Status      int     `json:"status"`
+	Duration    float64 `json:"duration"` // in milliseconds
+	ContentType string  `json:"contentType"`
+	Size        int     `json:"size"`
+	Policy      string  `json:"policy,omitempty"`
+	Source      string  `json:"source,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	ResendOf    uint32  `json:"resendOf,omitempty"`
+}
+
+type jsonSearchHistory struct {
+	Total     int           `json:"total"`
+	Omitted   int           `json:"omitted,omitempty"` // older ones out of memory, not searched
+	Histories []jsonHistory `json:"histories"`
+}
+
+func newJsonHistory(h *cache.UrlHistory) jsonHistory {
+	j := jsonHistory{}
+	j.ID = h.ID
+	j.Time = h.Time.Format(time.RFC3339Nano)
+	j.Method = h.Method
+	j.Url = h.Url
+	j.Status = h.ResponseCode
+	j.Duration = float64(h.Duration) / float64(time.Millisecond)
+	j.ContentType = h.ResponseHeader.Get("Content-Type")
+	j.Size = len(h.Bytes)
+	j.Policy = h.Policy
+	j.Source = h.ContentSource
+	if h.Error != nil {
+		j.Error = h.Error.Error()
+	}
+
+	j.ResendOf = h.ResendOf
+
+	return j
+}
+
+// searchHistory writes histories of f which match the filter as JSON, only
+// the last `limit' ones if set.
+func (p *Proxy) searchHistory(w http.ResponseWriter, r *http.Request, f *life.Life) {
+	r.ParseForm()
+	filter, err := cache.ParseHistoryFilter(r.Form)
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	histories := filter.Filter(f.Histories())
+	j := jsonSearchHistory{len(histories), f.HistoryUsage().Omitted(), make([]jsonHistory, 0, len(histories))}
+	if s := r.Form.Get("limit"); len(s) > 0 {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			w.WriteHeader(400)
+			fmt.Fprintln(w, "wrong limit:", s)
+			return
+		}
+
+		if len(histories) > limit {
+			histories = histories[len(histories)-limit:]
+		}
+	}
+
+	for _, h := range histories {
+		j.Histories = append(j.Histories, newJsonHistory(h))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(j)
+}
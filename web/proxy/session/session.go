@@ -0,0 +1,246 @@
+package session
+
+import (
+	"github.com/benbearchen/asuran/util"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"crypto/md5"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const exchangeSuffix = ".gob"
+
+// Session is recorded exchanges in order, each of which is a file under
+// the dir of session. Only an index is kept in memory, and exchanges are
+// read from files when matched.
+type Session struct {
+	name      string
+	dir       string
+	exchanges []exchangeIndex
+
+	lock sync.RWMutex
+}
+
+type exchangeIndex struct {
+	method string
+	url    string
+	body   [md5.Size]byte
+	file   string
+}
+
+func newExchangeIndex(c *cache.UrlCache, file string) exchangeIndex {
+	return exchangeIndex{c.Method, c.Url, md5.Sum(c.RequestBody()), file}
+}
+
+// Dir keeps sessions under a dir, loaded on first use.
+type Dir struct {
+	dir      string
+	sessions map[string]*Session
+
+	lock sync.Mutex
+}
+
+func New(dir string) *Dir {
+	d := new(Dir)
+	d.dir = dir
+	d.sessions = make(map[string]*Session)
+
+	util.MakeDir(dir)
+	return d
+}
+
+// Open loads the session of name, which is empty if it isn't recorded.
+func (d *Dir) Open(name string) (*Session, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if s, ok := d.sessions[name]; ok {
+		return s, nil
+	}
+
+	s := &Session{name: name, dir: filepath.Join(d.dir, name)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	d.sessions[name] = s
+	return s, nil
+}
+
+// Record appends c to the session of name. Failed exchanges can't be saved.
+func (d *Dir) Record(name string, c *cache.UrlCache) error {
+	if c.Error != nil {
+		return fmt.Errorf("can't record error: %v", c.Error)
+	}
+
+	s, err := d.Open(name)
+	if err != nil {
+		return err
+	}
+
+	return s.append(c)
+}
+
+// List returns names of sessions recorded.
+func (d *Dir) List() []string {
+	files, _ := filepath.Glob(filepath.Join(d.dir, "*"))
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil && fi.IsDir() {
+			names = append(names, filepath.Base(file))
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (s *Session) load() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+exchangeSuffix))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+	s.exchanges = make([]exchangeIndex, 0, len(files))
+	for _, file := range files {
+		c, err := readExchange(file)
+		if err != nil {
+			return fmt.Errorf("session %s broken: %v", s.name, err)
+		}
+
+		s.exchanges = append(s.exchanges, newExchangeIndex(c, file))
+	}
+
+	return nil
+}
+
+func readExchange(file string) (*cache.UrlCache, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	c := new(cache.UrlCache)
+	if err := gob.NewDecoder(f).Decode(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *Session) append(c *cache.UrlCache) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := util.MakeDir(s.dir); err != nil {
+		return err
+	}
+
+	file := filepath.Join(s.dir, fmt.Sprintf("%08d", len(s.exchanges)+1)+exchangeSuffix)
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(f).Encode(c)
+	f.Close()
+	if err != nil {
+		os.Remove(file)
+		return err
+	}
+
+	s.exchanges = append(s.exchanges, newExchangeIndex(c, file))
+	return nil
+}
+
+func (s *Session) Name() string {
+	return s.name
+}
+
+func (s *Session) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.exchanges)
+}
+
+func exchangeKey(method, url string, body [md5.Size]byte, withBody bool) string {
+	key := method + " " + url
+	if withBody {
+		key += fmt.Sprintf(" %x", body)
+	}
+
+	return key
+}
+
+// Replayer matches requests to exchanges of a session, and remembers how
+// many times each request is matched for ordered replay.
+type Replayer struct {
+	session *Session
+	ordered bool
+	body    bool
+	times   map[string]int
+
+	lock sync.Mutex
+}
+
+func NewReplayer(s *Session, ordered, body bool) *Replayer {
+	return &Replayer{s, ordered, body, make(map[string]int), sync.Mutex{}}
+}
+
+func (r *Replayer) Session() *Session {
+	return r.session
+}
+
+// Match returns the first exchange of the request, or the n-th one for
+// the n-th same request if ordered, which is nil after all are replayed
+// or if its file can't be read.
+func (r *Replayer) Match(method, url string, body []byte) *cache.UrlCache {
+	key := exchangeKey(method, url, md5.Sum(body), r.body)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n := 0
+	if r.ordered {
+		n = r.times[key]
+		r.times[key] = n + 1
+	}
+
+	file := r.session.find(key, r.body, n)
+	if len(file) == 0 {
+		return nil
+	}
+
+	c, err := readExchange(file)
+	if err != nil {
+		return nil
+	}
+
+	return c
+}
+
+// find returns the file of the n-th exchange of key.
+func (s *Session) find(key string, withBody bool, n int) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, e := range s.exchanges {
+		if exchangeKey(e.method, e.url, e.body, withBody) != key {
+			continue
+		} else if n == 0 {
+			return e.file
+		}
+
+		n--
+	}
+
+	return ""
+}
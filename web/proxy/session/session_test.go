@@ -0,0 +1,104 @@
+package session
+
+import (
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func exchange(method, url, body, content string) *cache.UrlCache {
+	c := &cache.UrlCache{Method: method, Url: url, Bytes: []byte(content), ResponseCode: 200}
+	if len(body) > 0 {
+		c.PostBody = []byte(body)
+	}
+
+	return c
+}
+
+func content(c *cache.UrlCache) string {
+	if c == nil {
+		return "<nil>"
+	}
+
+	return string(c.Bytes)
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	d := New(dir)
+	records := []*cache.UrlCache{
+		exchange("GET", "http://a.com/x", "", "x1"),
+		exchange("POST", "http://a.com/p", "a=1", "p1"),
+		exchange("GET", "http://a.com/x", "", "x2"),
+		exchange("POST", "http://a.com/p", "a=2", "p2"),
+	}
+
+	for _, c := range records {
+		if err := d.Record("s1", c); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+
+	failed := exchange("GET", "http://a.com/e", "", "")
+	failed.Error = os.ErrNotExist
+	if d.Record("s1", failed) == nil {
+		t.Errorf("failed exchange shouldn't be recorded")
+	}
+
+	if names := d.List(); len(names) != 1 || names[0] != "s1" {
+		t.Errorf("sessions: %v", names)
+	}
+
+	s, err := New(dir).Open("s1")
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	} else if s.Len() != len(records) {
+		t.Fatalf("reopen %d exchanges, want %d", s.Len(), len(records))
+	}
+
+	r := NewReplayer(s, false, false)
+	for i := 0; i < 2; i++ {
+		if c := content(r.Match("GET", "http://a.com/x", nil)); c != "x1" {
+			t.Errorf("replay #%d: %s", i, c)
+		}
+	}
+
+	if c := r.Match("GET", "http://a.com/p", nil); c != nil {
+		t.Errorf("method should match: %s", content(c))
+	}
+
+	r = NewReplayer(s, true, false)
+	for _, want := range []string{"x1", "x2", "<nil>"} {
+		if c := content(r.Match("GET", "http://a.com/x", nil)); c != want {
+			t.Errorf("ordered replay: %s, want %s", c, want)
+		}
+	}
+
+	r = NewReplayer(s, false, true)
+	if c := content(r.Match("POST", "http://a.com/p", []byte("a=2"))); c != "p2" {
+		t.Errorf("body replay: %s", c)
+	}
+
+	if c := r.Match("POST", "http://a.com/p", []byte("a=3")); c != nil {
+		t.Errorf("body should match: %s", content(c))
+	}
+
+	os.Remove(filepath.Join(dir, "s1", "00000001.gob"))
+	if c := NewReplayer(s, false, false).Match("GET", "http://a.com/x", nil); c != nil {
+		t.Errorf("exchanges should be read from files: %s", content(c))
+	}
+
+	empty, err := d.Open("s2")
+	if err != nil || empty.Len() != 0 {
+		t.Errorf("new session: %v, %v", empty, err)
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	gonet "github.com/benbearchen/asuran/net"
+	"github.com/benbearchen/asuran/policy"
+
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// runTcpScript runs steps on the hijacked connection of w, and returns
+// all bytes sent. The connection is closed at the end of script.
+func runTcpScript(w http.ResponseWriter, writeWrapper func(io.Writer) io.Writer, steps []policy.TcpStep) ([]byte, error) {
+	conn, rw, err := gonet.TryHijack(w)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.Flush()
+
+	var out io.Writer = conn
+	if writeWrapper != nil {
+		out = writeWrapper(conn)
+	}
+
+	sent := make([]byte, 0)
+	for _, s := range steps {
+		switch s.Action {
+		case policy.TcpSend:
+			n, err := out.Write(s.Data)
+			sent = append(sent, s.Data[:n]...)
+			if err != nil {
+				conn.Close()
+				return sent, err
+			}
+
+			flushWriter(out)
+		case policy.TcpSleep:
+			<-time.NewTimer(s.Duration).C
+		case policy.TcpHalfClose:
+			c, ok := conn.(interface {
+				CloseWrite() error
+			})
+			if !ok {
+				conn.Close()
+				return sent, fmt.Errorf("can't half close %v", conn)
+			}
+
+			if err := c.CloseWrite(); err != nil {
+				conn.Close()
+				return sent, err
+			}
+		case policy.TcpReset:
+			resetConn(conn)
+			return sent, nil
+		case policy.TcpClose:
+			conn.Close()
+			return sent, nil
+		}
+	}
+
+	conn.Close()
+	return sent, nil
+}
+
+// tcpScriptSize counts bytes to send by steps.
+func tcpScriptSize(steps []policy.TcpStep) int {
+	n := 0
+	for _, s := range steps {
+		if s.Action == policy.TcpSend {
+			n += len(s.Data)
+		}
+	}
+
+	return n
+}
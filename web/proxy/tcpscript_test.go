@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/benbearchen/asuran/policy"
+
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func TestRunTcpScript(t *testing.T) {
+	steps, err := policy.ParseTcpScript("send:HTTP%2F1.1%20200%20OK%0D%0A,sleep:300ms,send:%0D%0Aab,halfclose")
+	if err != nil {
+		t.Fatalf("ParseTcpScript() failed: %v", err)
+	}
+
+	sent := make(chan []byte, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := runTcpScript(w, nil, steps)
+		if err != nil {
+			t.Errorf("runTcpScript() failed: %v", err)
+		}
+
+		sent <- b
+	}))
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	defer conn.Close()
+	start := time.Now()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: g.cn\r\n\r\n"))
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Errorf("read failed: %v", err)
+	} else if string(b) != "HTTP/1.1 200 OK\r\n\r\nab" {
+		t.Errorf("read wrong: %q", b)
+	} else if d := time.Now().Sub(start); d < 300*time.Millisecond {
+		t.Errorf("read too fast: %v", d)
+	}
+
+	if b := <-sent; string(b) != "HTTP/1.1 200 OK\r\n\r\nab" {
+		t.Errorf("runTcpScript() sent wrong: %q", b)
+	}
+}
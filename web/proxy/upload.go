@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"github.com/benbearchen/asuran/policy"
+	"github.com/benbearchen/asuran/web/proxy/cache"
+
+	"io"
+	"net/http"
+	"time"
+)
+
+// uploadReader delays and throttles reading of the request body, and
+// records how long the upload takes.
+type uploadReader struct {
+	r     io.ReadCloser
+	delay time.Duration
+	start time.Time
+	end   time.Time
+}
+
+func newUploadReader(up *policy.UrlPolicy, r *http.Request) *uploadReader {
+	if up == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	speed := up.UploadSpeed()
+	delay := up.UploadDelay()
+	if speed == nil && delay == nil {
+		return nil
+	}
+
+	u := &uploadReader{r: r.Body}
+	if speed != nil {
+		u.r = newSpeedReader(speed.Speed(), u.r)
+	}
+
+	if delay != nil {
+		u.delay = delay.Duration()
+	}
+
+	return u
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if u.start.IsZero() {
+		u.start = time.Now()
+		if u.delay > 0 {
+			<-time.NewTimer(u.delay).C
+		}
+	}
+
+	n, err := u.r.Read(p)
+	if err != nil && u.end.IsZero() {
+		u.end = time.Now()
+	}
+
+	return n, err
+}
+
+func (u *uploadReader) Close() error {
+	return u.r.Close()
+}
+
+func (u *uploadReader) record(c *cache.UrlCache) {
+	if u == nil || u.start.IsZero() {
+		return
+	}
+
+	end := u.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	c.UploadDelay = u.delay
+	c.UploadDuration = end.Sub(u.start)
+}